@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/JackBekket/reflexia/internal/api"
+	"github.com/JackBekket/reflexia/pkg/cache"
 	"github.com/JackBekket/reflexia/pkg/config"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -58,9 +59,17 @@ func Run(cfg config.Config) {
 		middleware.Recoverer,
 	)
 
+	// Opened once, since the bolt backend locks its database file
+	responseCache, err := cache.Open(cfg.CacheBackend, cfg.CachePath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("open cache")
+	}
+	defer responseCache.Close()
+
 	apiService := api.APIService{
 		Workdir: workdir,
 		Config:  cfg,
+		Cache:   responseCache,
 	}
 
 	projectConfigsInteractor := usecase.NewInteractor(apiService.ProjectConfigsGet)
@@ -74,6 +83,18 @@ func Run(cfg config.Config) {
 	)
 	webService.Method(http.MethodGet, "/project_configs", nethttp.NewHandler(projectConfigsInteractor))
 
+	projectConfigsValidateInteractor := usecase.NewInteractor(apiService.ProjectConfigsValidatePost)
+	projectConfigsValidateInteractor.SetTitle("Validate project configs")
+	projectConfigsValidateInteractor.SetDescription(
+		"Validates the uploaded .toml project config content, or every project config of the search path " +
+			"if the content is empty, and reports the problems with their file positions",
+	)
+	projectConfigsValidateInteractor.SetExpectedErrors(
+		status.Internal,
+	)
+	webService.Method(http.MethodPost, "/project_configs/validate", nethttp.NewHandler(projectConfigsValidateInteractor))
+	webService.Method(http.MethodGet, "/project_configs/schema", http.HandlerFunc(apiService.ProjectConfigSchemaGet))
+
 	reflectInteractor := usecase.NewInteractor(apiService.ReflectPost)
 	reflectInteractor.SetTitle("Reflect")
 	reflectInteractor.SetDescription(
@@ -88,6 +109,8 @@ func Run(cfg config.Config) {
 	)
 
 	webService.Method(http.MethodPost, "/reflect", nethttp.NewHandler(reflectInteractor))
+	// Takes the /reflect input and responds with the zip archive of the static HTML documentation site
+	webService.Method(http.MethodPost, "/reflect/site", http.HandlerFunc(apiService.ReflectSitePost))
 
 	webService.Docs("/docs", swgui.New)
 
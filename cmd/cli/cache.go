@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JackBekket/reflexia/pkg/cache"
+	"github.com/JackBekket/reflexia/pkg/config"
+)
+
+const cacheUsage = `Usage: reflexia cache <command> [flags]
+
+Commands:
+  list             list cache entries, optionally filtered
+  inspect <key>    print entry metadata and content, a unique key prefix is enough
+  search <query>   list entries with source path, package, project, config or model containing the query
+  prune            delete entries matching the filters
+  size             report entries count and total size
+
+Run reflexia cache <command> -h for the command flags.
+`
+
+func runCacheCommand(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		fmt.Print(cacheUsage)
+		return errors.New("cache command is required")
+	}
+	command, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("cache "+command, flag.ExitOnError)
+	flags.StringVar(&cfg.CachePath, "a", cfg.CachePath, "cache folder path (defaults to .reflexia_cache), the bolt cache backend keeps its cache.db there")
+	flags.StringVar(&cfg.CacheBackend, "ab", cfg.CacheBackend, "cache backend: fs, bolt or memory (defaults to fs)")
+
+	filter := cache.Filter{}
+	olderThan := ""
+	pruneAll := false
+	dryRun := false
+	switch command {
+	case "list", "search", "prune":
+		flags.StringVar(&filter.Model, "model", "", "match entries produced by the model")
+		flags.StringVar(&filter.Project, "project", "", "match entries of the project")
+		flags.StringVar(&filter.ProjectConfig, "config", "", "match entries produced with the project config, e.g. go.toml")
+		flags.StringVar(&olderThan, "older", "", "match entries older than the age, e.g. 36h or 30d")
+	}
+	if command == "prune" {
+		flags.BoolVar(&pruneAll, "all", false, "allow pruning without filters, deleting every entry")
+		flags.BoolVar(&dryRun, "n", false, "only list entries which would be deleted")
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if olderThan != "" {
+		var err error
+		if filter.OlderThan, err = parseAge(olderThan); err != nil {
+			return fmt.Errorf("parse age %s: %w", olderThan, err)
+		}
+	}
+
+	c, err := cache.Open(cfg.CacheBackend, cfg.CachePath)
+	if err != nil {
+		return fmt.Errorf("open cache: %w", err)
+	}
+	defer c.Close()
+
+	switch command {
+	case "list":
+		entries, err := cache.Find(c, filter)
+		if err != nil {
+			return err
+		}
+		printCacheEntries(entries)
+
+	case "search":
+		if flags.NArg() == 0 {
+			return errors.New("search query is required")
+		}
+		filter.Query = strings.Join(flags.Args(), " ")
+		entries, err := cache.Find(c, filter)
+		if err != nil {
+			return err
+		}
+		printCacheEntries(entries)
+
+	case "inspect":
+		if flags.NArg() == 0 {
+			return errors.New("entry key is required")
+		}
+		entry, err := cache.LoadPrefix(c, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		printCacheEntry(entry)
+
+	case "prune":
+		if filter == (cache.Filter{}) && !pruneAll {
+			return errors.New("no prune filters set, use -all to delete every entry")
+		}
+		var entries []cache.Entry
+		if dryRun {
+			entries, err = cache.Find(c, filter)
+		} else {
+			entries, err = cache.Prune(c, filter)
+		}
+		if err != nil {
+			return err
+		}
+		printCacheEntries(entries)
+		if dryRun {
+			fmt.Printf("%d entries would be deleted\n", len(entries))
+		} else {
+			fmt.Printf("%d entries deleted\n", len(entries))
+		}
+
+	case "size":
+		entries, err := c.List()
+		if err != nil {
+			return err
+		}
+		total := int64(0)
+		for _, entry := range entries {
+			total += entry.Size
+		}
+		fmt.Printf("%d entries, %s total\n", len(entries), formatBytes(total))
+
+	default:
+		fmt.Print(cacheUsage)
+		return fmt.Errorf("unknown cache command %s", command)
+	}
+
+	return nil
+}
+
+func printCacheEntries(entries []cache.Entry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tCREATED\tMODEL\tPROJECT\tCONFIG\tSOURCE\tSIZE")
+	total := int64(0)
+	for _, entry := range entries {
+		metadata := entry.Metadata
+		source := metadata.SourcePath
+		if source == "" {
+			source = metadata.Package
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.Key[:min(len(entry.Key), 12)],
+			metadata.CreatedAt.Format(time.DateTime),
+			orDash(metadata.Model),
+			orDash(metadata.Project),
+			orDash(metadata.ProjectConfig),
+			orDash(source),
+			formatBytes(entry.Size),
+		)
+		total += entry.Size
+	}
+	w.Flush()
+	fmt.Printf("%d entries, %s\n", len(entries), formatBytes(total))
+}
+
+func printCacheEntry(entry cache.Entry) {
+	metadata := entry.Metadata
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Key:\t%s\n", entry.Key)
+	fmt.Fprintf(w, "Created:\t%s\n", metadata.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "Model:\t%s\n", orDash(metadata.Model))
+	fmt.Fprintf(w, "Project:\t%s\n", orDash(metadata.Project))
+	fmt.Fprintf(w, "Project config:\t%s\n", orDash(metadata.ProjectConfig))
+	fmt.Fprintf(w, "Prompts:\t%s\n", orDash(metadata.Prompts))
+	fmt.Fprintf(w, "Package:\t%s\n", orDash(metadata.Package))
+	fmt.Fprintf(w, "Source path:\t%s\n", orDash(metadata.SourcePath))
+	fmt.Fprintf(w, "Prompt:\t%s, ~%d tokens\n", formatBytes(int64(metadata.PromptBytes)), metadata.PromptTokens)
+	fmt.Fprintf(w, "Response:\t%s, ~%d tokens\n", formatBytes(int64(metadata.ResponseBytes)), metadata.ResponseTokens)
+	fmt.Fprintf(w, "Stored size:\t%s\n", formatBytes(entry.Size))
+	w.Flush()
+	fmt.Printf("\n%s\n", entry.Content)
+}
+
+// parseAge extends time.ParseDuration with the d (days) unit
+func parseAge(age string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(age, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(age)
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -5,14 +5,19 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/JackBekket/reflexia/pkg/config"
+	packagerunner "github.com/JackBekket/reflexia/pkg/package_runner"
 	"github.com/JackBekket/reflexia/pkg/project"
 	"github.com/JackBekket/reflexia/pkg/reflexia"
+	"github.com/JackBekket/reflexia/pkg/site"
 
 	agentConfig "github.com/Swarmind/libagent/pkg/config"
 )
@@ -21,9 +26,24 @@ func Run(cfg config.Config) {
 	zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		if err := runCacheCommand(cfg, os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("cache command")
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "configs" {
+		if err := runConfigsCommand(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("configs command")
+		}
+		return
+	}
+
 	ctx := context.Background()
 
-	reflexiaOpts, err := readReflexiaCall(cfg)
+	reportPath := ""
+	reflexiaOpts, err := readReflexiaCall(cfg, &reportPath)
 	if err != nil {
 		log.Fatal().Err(err).Msg("init config")
 	}
@@ -33,10 +53,28 @@ func Run(cfg config.Config) {
 		reflexiaOpts.LocalWorkdir = wd
 	}
 
+	// The JSON lines report records are written as the packages are done
+	var reportFile *os.File
+	if filepath.Ext(reportPath) == ".jsonl" {
+		if reportFile, err = os.Create(reportPath); err != nil {
+			log.Fatal().Err(err).Msg("create report")
+		}
+		reflexiaOpts.ReportTo = reportFile
+	}
+
 	artifacts, err := reflexiaOpts.Run(ctx)
 	if err != nil {
 		log.Fatal().Err(err).Msg("reflexia run")
 	}
+	if reportFile != nil {
+		if err := reportFile.Close(); err != nil {
+			log.Fatal().Err(err).Msg("write report")
+		}
+	} else if reportPath != "" {
+		if err := writeReport(reportPath, reflexia.NewReport(artifacts)); err != nil {
+			log.Fatal().Err(err).Msg("write report")
+		}
+	}
 
 	printEmptyWarning(
 		"[WARN] %d fallback attempts for files\n",
@@ -55,11 +93,57 @@ func Run(cfg config.Config) {
 		artifacts.PackageRunnerStats.EmptyPackageResponses,
 	)
 
+	if artifacts.PackageRunnerStats.ProjectFallback {
+		fmt.Printf("[WARN] fallback attempt for the project summary\n")
+	}
+	if artifacts.PackageRunnerStats.ProjectEmpty {
+		fmt.Printf("[WARN] empty LLM response for the project summary\n")
+	}
+	if len(artifacts.ConfigRanking) > 0 {
+		fmt.Printf("Project config ranking:\n")
+		for _, score := range artifacts.ConfigRanking {
+			fmt.Printf(" - %v\n", score)
+		}
+	}
+	for _, project := range artifacts.Projects {
+		if project.PackageRunnerStats.ProjectReadme != "" {
+			fmt.Printf("Project %s README: %s\n", project.Project, project.PackageRunnerStats.ProjectReadme)
+		}
+	}
+
+	if len(artifacts.UnchangedPackages) > 0 {
+		fmt.Printf("Skipped %d unchanged packages\n", len(artifacts.UnchangedPackages))
+	}
+
+	printUsage(artifacts.PackageRunnerStats.Usage)
+
+	if artifacts.PackageRunnerStats.Retries > 0 {
+		fmt.Printf("[WARN] %d retried LLM request attempts\n", artifacts.PackageRunnerStats.Retries)
+	}
+
 	if artifacts.PullRequestURL != nil {
 		fmt.Printf("Pull request created: %s\n", *artifacts.PullRequestURL)
 	}
 }
 
+func printUsage(usage packagerunner.Usage) {
+	estimated := ""
+	if usage.EstimatedTokens {
+		estimated = " (partially estimated)"
+	}
+	fmt.Printf(
+		"LLM requests: %d, cache hits: %d, cache misses: %d\n",
+		usage.Requests, usage.CacheHits, usage.CacheMisses,
+	)
+	fmt.Printf(
+		"Tokens%s: %d prompt, %d completion, LLM wall time: %s\n",
+		estimated, usage.PromptTokens, usage.CompletionTokens, usage.Duration.Round(time.Millisecond),
+	)
+	if usage.Cost > 0 {
+		fmt.Printf("Cost: %.4f\n", usage.Cost)
+	}
+}
+
 func printEmptyWarning(message string, responses []string) {
 	if len(responses) == 0 {
 		return
@@ -70,12 +154,35 @@ func printEmptyWarning(message string, responses []string) {
 	}
 }
 
-func readReflexiaCall(cfg config.Config) (reflexia.ReflexiaCall, error) {
+// writeReport writes the JSON report, or the JSON lines one for the .jsonl path
+func writeReport(path string, report reflexia.Report) error {
+	format := reflexia.ReportJSON
+	if filepath.Ext(path) == ".jsonl" {
+		format = reflexia.ReportJSONL
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.Write(file, format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func readReflexiaCall(cfg config.Config, reportPath *string) (reflexia.ReflexiaCall, error) {
 	if err := godotenv.Load(); err != nil {
 		log.Warn().Err(err).Msg("load .env file")
 	}
 
-	flag.StringVar(&cfg.CachePath, "a", cfg.CachePath, "cache folder path (defaults to .reflexia_cache)")
+	flag.StringVar(&cfg.CachePath, "a", cfg.CachePath, "cache folder path (defaults to .reflexia_cache), the bolt cache backend keeps its cache.db there")
+	flag.StringVar(&cfg.CacheBackend, "ab", cfg.CacheBackend, "cache backend: fs, bolt or memory (defaults to fs)")
+	flag.StringVar(&cfg.LLMProvider, "m", cfg.LLMProvider, "LLM provider: openai, ollama, anthropic or fake (defaults to openai)")
+	flag.IntVar(&cfg.LLMMaxRetries, "rt", cfg.LLMMaxRetries, "max retries of the failed LLM requests")
+	flag.IntVar(&cfg.LLMRequestsPerMinute, "rpm", cfg.LLMRequestsPerMinute, "LLM requests per minute limit, 0 is unlimited")
+	flag.IntVar(&cfg.LLMTokensPerMinute, "tpm", cfg.LLMTokensPerMinute, "LLM estimated tokens per minute limit, 0 is unlimited")
+	flag.StringVar(&cfg.LLMPricesPath, "prices", cfg.LLMPricesPath, "toml price table per model for the usage cost accounting")
 	flag.StringVar(&cfg.EmbeddingsAIURL, "eu", cfg.EmbeddingsAIURL, "embeddings AI URL")
 	flag.StringVar(&cfg.EmbeddingsAIToken, "ea", cfg.EmbeddingsAIToken, "embeddings AI API Key")
 	flag.StringVar(&cfg.EmbeddingsDBURL, "ed", cfg.EmbeddingsDBURL, "embeddings pgxpool DB connect URL")
@@ -89,9 +196,13 @@ func readReflexiaCall(cfg config.Config) (reflexia.ReflexiaCall, error) {
 	reflexiaOpts := reflexia.ReflexiaCall{
 		Config:      cfg,
 		AgentConfig: agentCfg,
-		ChooserFunc: project.CLIChooser,
+		ChooserFunc: project.ScoreChooser,
 		PrintTo:     os.Stdout,
 	}
+	// The interactive chooser is used only if stdin is a terminal
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		reflexiaOpts.ChooserFunc = project.CLIChooser
+	}
 
 	reflexiaOpts.GithubToken = os.Getenv("GH_TOKEN")
 	flag.StringVar(&reflexiaOpts.RepositoryURL, "g", "", "valid link for github repository")
@@ -99,8 +210,13 @@ func readReflexiaCall(cfg config.Config) (reflexia.ReflexiaCall, error) {
 	flag.StringVar(&reflexiaOpts.GithubUsername, "u", "", "github username for ssh auth")
 	flag.StringVar(&reflexiaOpts.GithubToken, "t", reflexiaOpts.GithubToken, "github token for ssh auth")
 
-	flag.StringVar(&reflexiaOpts.WithConfigFile, "l", "", "config filename in project_config to use")
+	flag.StringVar(&reflexiaOpts.WithConfigFile, "l", "", "project config file path, or config filename in the search path to use")
 	flag.StringVar(&reflexiaOpts.ExactPackages, "p", "", "exact package names, ',' delimited")
+	flag.IntVar(&reflexiaOpts.Parallelism, "j", 1, "number of concurrent LLM requests")
+	flag.StringVar(&reflexiaOpts.OutputRoot, "o", "", "output directory mirroring the package tree, the files are written in place if empty")
+	siteRoot := flag.String("site", "", "directory of the static HTML documentation site")
+	flag.StringVar(&reflexiaOpts.SiteFormat, "site-format", site.FormatHTML, "documentation site layout: "+strings.Join(site.Formats, ", "))
+	flag.StringVar(reportPath, "report", "", "JSON report file path of the run, JSON lines for the .jsonl extension")
 
 	flag.BoolFunc("w",
 		"do a commit to a _autodoc suffixed branch and raise a PR",
@@ -132,6 +248,19 @@ func readReflexiaCall(cfg config.Config) (reflexia.ReflexiaCall, error) {
 			reflexiaOpts.OverwriteCache = true
 			return nil
 		})
+	flag.BoolFunc("i",
+		"document only the packages changed since the last run recorded in the .reflexia_manifest.json, "+
+			"the -g runs read it from the base branch, so they document everything until the docs PR is merged",
+		func(_ string) error {
+			reflexiaOpts.Incremental = true
+			return nil
+		})
+	flag.BoolFunc("all",
+		"document every detected project config root, such as backend/go.mod and web/package.json, in one run",
+		func(_ string) error {
+			reflexiaOpts.AllProjects = true
+			return nil
+		})
 	flag.BoolFunc("e", "Use Embeddings",
 		func(_ string) error {
 			reflexiaOpts.UseEmbeddings = true
@@ -139,6 +268,9 @@ func readReflexiaCall(cfg config.Config) (reflexia.ReflexiaCall, error) {
 		})
 
 	flag.Parse()
+	if *siteRoot != "" {
+		reflexiaOpts.SiteOutput = packagerunner.DirOutput(*siteRoot)
+	}
 
 	return reflexiaOpts, nil
 }
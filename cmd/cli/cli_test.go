@@ -1,196 +1,537 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
+	"github.com/JackBekket/reflexia/pkg/cache"
+	"github.com/JackBekket/reflexia/pkg/config"
+	packagerunner "github.com/JackBekket/reflexia/pkg/package_runner"
 	"github.com/JackBekket/reflexia/pkg/project"
+	"github.com/JackBekket/reflexia/pkg/provider"
+	"github.com/JackBekket/reflexia/pkg/reflexia"
 	"github.com/JackBekket/reflexia/pkg/summarize"
 	"github.com/Swarmind/libagent/pkg/agent/simple"
-	"github.com/Swarmind/libagent/pkg/config"
-	"github.com/rs/zerolog/log"
-	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
+	agentConfig "github.com/Swarmind/libagent/pkg/config"
 )
 
-func TestProcessWorkingDirectory(t *testing.T) {
-	t.Run(
-		"Working directory without links",
-		func(t *testing.T) {
-			workdir, err := processWorkingDirectory("", "", "", "")
-			if err != nil {
-				t.Fatal(err)
-			}
-			if !strings.Contains(workdir, "Reflexia") {
-				t.Fatalf("workdir %s is not Reflexia", workdir)
-			}
-		},
-	)
-	t.Run(
-		"Working directory with github link",
-		func(t *testing.T) {
-			workdir, err := processWorkingDirectory("https://github.com/JackBekket/GitHelper", "", "", "")
-			if err != nil {
-				t.Fatal(err)
-			}
-			if !strings.Contains(workdir, "GitHelper") {
-				t.Fatalf("workdir %s is not GitHelper", workdir)
-			}
-		},
-	)
-	t.Run(
-		"Working directory with github link using cached temp result",
-		func(t *testing.T) {
-			workdir, err := processWorkingDirectory("https://github.com/JackBekket/GitHelper", "", "", "")
-			if err != nil {
-				t.Fatal(err)
-			}
-			if !strings.Contains(workdir, "GitHelper") {
-				t.Fatalf("workdir %s is not GitHelper", workdir)
-			}
-		},
-	)
+var testProjectFiles = map[string]string{
+	"go.mod": "module example.com/sample\n\ngo 1.24\n",
+	"main.go": `package main
+
+import "example.com/sample/internal/greeter"
+
+func main() {
+	greeter.Greet("world")
 }
+`,
+	"internal/greeter/greeter.go": `package greeter
 
-func TestProcessWorkingDirectoryIgnore(t *testing.T) {
-	t.Run(
-		"Working directory with github link",
-		func(t *testing.T) {
-			workdir, err := processWorkingDirectory("https://github.com/JackBekket/LocalAI", "", "", "")
-			if err != nil {
-				t.Fatal(err)
-			}
-			if !strings.Contains(workdir, "LocalAI") {
-				t.Fatalf("workdir %s is not LocalAI", workdir)
-			}
-		},
-	)
+import "fmt"
 
+func Greet(name string) {
+	fmt.Println("Hello, " + name)
+}
+`,
+	"internal/greeter/broken.go": `package greeter
+
+func Broken() {}
+`,
+}
+
+// newTestProject writes the sample go project into the temp dir,
+// project configs are embedded so the working directory is left as is
+func newTestProject(t *testing.T) string {
+	t.Helper()
+	workdir := t.TempDir()
+	for path, content := range testProjectFiles {
+		path = filepath.Join(workdir, path)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return workdir
 }
 
 func TestGetProjectConfig(t *testing.T) {
-	t.Run(
-		"Project language .toml config",
-		func(t *testing.T) {
-			projectConfigVariants, err := project.GetProjectConfig(
-				"temp/JackBekket/GitHelper/master", "", false,
-			)
-			if err != nil {
-				t.Fatal(err)
-			}
-			failed := true
-			for filename := range projectConfigVariants {
-				if filename == "go.toml" {
-					failed = false
-				}
-			}
-			if failed {
-				t.Fatal("no go.toml in go project detected!")
-			}
-		},
-	)
+	workdir := newTestProject(t)
+
+	projectConfigVariants, err := project.GetProjectConfig(workdir, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := projectConfigVariants["go.toml"]; !ok {
+		t.Fatal("no go.toml in go project detected!")
+	}
+	if len(projectConfigVariants) != 1 {
+		t.Fatalf("expected only go.toml to be detected, got %d variants", len(projectConfigVariants))
+	}
 }
 
 func TestBuildPackageFiles(t *testing.T) {
-	t.Run(
-		"Project package files",
-		func(t *testing.T) {
-			projectConfigVariants, err := project.GetProjectConfig(
-				"temp/JackBekket/GitHelper/master", "", false,
-			)
-			if err != nil {
-				t.Fatal(err)
-			}
-			var projectConfig *project.ProjectConfig
-			for filename, config := range projectConfigVariants {
-				if filename == "go.toml" {
-					projectConfig = config
-					break
-				}
-			}
-			pkgFiles, err := projectConfig.BuildPackageFiles()
-			if err != nil {
-				t.Fatal(err)
-			}
-			failed := true
-			for pkg := range pkgFiles {
-				if pkg == "main" {
-					failed = false
-					break
-				}
-			}
-			if failed {
-				t.Fatal("no main package in JackBekket/GitHelper project")
-			}
-		},
-	)
+	workdir := newTestProject(t)
+
+	projectConfigVariants, err := project.GetProjectConfig(workdir, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgFiles, err := projectConfigVariants["go.toml"].BuildPackageFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pkgFiles[".:main"]; !ok {
+		t.Fatalf("no main package in the test project, got %v", pkgFiles)
+	}
+	if files := pkgFiles["internal/greeter:greeter"]; len(files) != 2 {
+		t.Fatalf("expected two greeter package files, got %v", files)
+	}
 }
 
 func TestLLMResponse(t *testing.T) {
-	projectConfigVariants, err := project.GetProjectConfig(
-		"temp/JackBekket/GitHelper/master", "", false,
-	)
+	llm, err := provider.NewFakeModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	summarizeService := &summarize.SummarizeService{
+		Agent:       &simple.Agent{LLM: llm},
+		IgnoreCache: true,
+		Model:       provider.Fake,
+	}
+
+	testPrompt := "This is a test prompt. Write a hex notation of red color in reply."
+	resp, err := summarizeService.LLMRequest(context.Background(), "%s", testPrompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == "" {
+		t.Fatal("Empty output from LLM")
+	}
+	again, err := summarizeService.LLMRequest(context.Background(), "%s", testPrompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != resp {
+		t.Fatal("fake LLM response is not deterministic")
+	}
+}
+
+func TestReflexiaRun(t *testing.T) {
+	workdir := newTestProject(t)
+
+	reflexiaCall := reflexia.ReflexiaCall{
+		LocalWorkdir:    workdir,
+		WithConfigFile:  "go.toml",
+		WithFileSummary: true,
+		Config: config.Config{
+			CacheBackend: cache.BackendMemory,
+			LLMProvider:  provider.Fake,
+			LLMProviderSettings: provider.Settings{
+				FakeRules: []provider.FakeRule{
+					{Match: "func Broken", Empty: true},
+					{Match: "summary of project package files", Empty: true, Times: 1},
+				},
+			},
+		},
+		AgentConfig: agentConfig.Config{Model: provider.Fake},
+		ChooserFunc: project.FirstChooser,
+		SiteOutput:  packagerunner.NewMemoryOutput(),
+		PrintTo:     io.Discard,
+	}
+
+	artifacts, err := reflexiaCall.Run(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
-	var projectConfig *project.ProjectConfig
-	for filename, config := range projectConfigVariants {
-		if filename == "go.toml" {
-			projectConfig = config
-			break
+	stats := artifacts.PackageRunnerStats
+
+	if !slices.Equal(stats.EmptyFileResponses, []string{
+		filepath.Join(workdir, "internal/greeter/broken.go"),
+	}) {
+		t.Fatalf("unexpected empty file responses %v", stats.EmptyFileResponses)
+	}
+	if len(stats.FallbackPackageResponses) != 1 {
+		t.Fatalf("expected one package fallback, got %v", stats.FallbackPackageResponses)
+	}
+	if len(stats.EmptyPackageResponses) != 0 {
+		t.Fatalf("unexpected empty package responses %v", stats.EmptyPackageResponses)
+	}
+	if stats.ProjectReadme != filepath.Join(workdir, "README.md") || stats.ProjectEmpty {
+		t.Fatalf("unexpected project README %s", stats.ProjectReadme)
+	}
+	if stats.Usage.CacheMisses != stats.Usage.Requests || stats.Usage.PromptTokens == 0 {
+		t.Fatalf("unexpected usage %+v", stats.Usage)
+	}
+
+	for _, path := range []string{
+		"README.md",
+		"internal/greeter/README.md",
+		"internal/greeter/FILES.md",
+	} {
+		content, err := os.ReadFile(filepath.Join(workdir, path))
+		if err != nil {
+			t.Fatal(err)
 		}
+		if !strings.Contains(string(content), "Fake summary") {
+			t.Fatalf("%s does not contain the fake summary:\n%s", path, content)
+		}
+	}
+
+	page, err := reflexiaCall.SiteOutput.ReadFile("files/internal_greeter_greeter.go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "Fake summary") || !strings.Contains(string(page), "file://") {
+		t.Fatalf("site file page does not contain the summary and the source link:\n%s", page)
+	}
+}
+
+func TestReflexiaIncrementalRun(t *testing.T) {
+	workdir := newTestProject(t)
+
+	reflexiaCall := reflexia.ReflexiaCall{
+		LocalWorkdir:   workdir,
+		WithConfigFile: "go.toml",
+		Incremental:    true,
+		Config: config.Config{
+			CacheBackend: cache.BackendMemory,
+			LLMProvider:  provider.Fake,
+		},
+		AgentConfig: agentConfig.Config{Model: provider.Fake},
+		ChooserFunc: project.FirstChooser,
+		PrintTo:     io.Discard,
 	}
 
-	agentCfg, err := config.NewConfig()
+	artifacts, err := reflexiaCall.Run(context.Background())
 	if err != nil {
-		log.Fatal().Err(err).Msg("config.NewConfig")
+		t.Fatal(err)
+	}
+	if len(artifacts.PackageRunnerStats.PackageUsage) != 2 || len(artifacts.UnchangedPackages) != 0 {
+		t.Fatalf("expected the first run to document all packages, got %v", artifacts.PackageRunnerStats.PackageUsage)
 	}
-	if agentCfg.AIURL == "" {
-		log.Fatal().Err(err).Msg("empty AI URL")
+	if _, err := os.Stat(filepath.Join(workdir, reflexia.ManifestFilename)); err != nil {
+		t.Fatal(err)
 	}
-	if agentCfg.AIToken == "" {
-		log.Fatal().Err(err).Msg("empty AI Token")
+
+	// Project README is not regenerated in place since the README.md exists already
+	mainReadme := filepath.Join(workdir, "README.md")
+	if err := os.WriteFile(mainReadme, []byte("untouched"), 0644); err != nil {
+		t.Fatal(err)
 	}
-	if agentCfg.Model == "" {
-		log.Fatal().Err(err).Msg("empty model")
+	greeterFile := filepath.Join(workdir, "internal/greeter/greeter.go")
+	if err := os.WriteFile(greeterFile, []byte(testProjectFiles["internal/greeter/greeter.go"]+"\nfunc Bye() {}\n"), 0644); err != nil {
+		t.Fatal(err)
 	}
 
-	agent := &simple.Agent{}
+	artifacts, err = reflexiaCall.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(artifacts.UnchangedPackages, []string{".:main"}) {
+		t.Fatalf("unexpected unchanged packages %v", artifacts.UnchangedPackages)
+	}
+	if _, ok := artifacts.PackageRunnerStats.PackageUsage["internal/greeter:greeter"]; !ok {
+		t.Fatalf("changed greeter package is not documented, got %v", artifacts.PackageRunnerStats.PackageUsage)
+	}
+	content, err := os.ReadFile(mainReadme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "untouched" {
+		t.Fatal("existing README is overwritten")
+	}
+	if artifacts.PackageRunnerStats.ProjectReadme != filepath.Join(workdir, "README_GENERATED.md") {
+		t.Fatalf("unexpected project README %s", artifacts.PackageRunnerStats.ProjectReadme)
+	}
 
-	llm, err := openai.New(
-		openai.WithBaseURL(agentCfg.AIURL),
-		openai.WithToken(agentCfg.AIToken),
-		openai.WithModel(agentCfg.Model),
-		openai.WithAPIVersion("v1"),
-	)
+	artifacts, err = reflexiaCall.Run(context.Background())
 	if err != nil {
-		log.Fatal().Err(err).Msg("openai.New")
+		t.Fatal(err)
+	}
+	if len(artifacts.PackageRunnerStats.PackageUsage) != 0 {
+		t.Fatalf("expected no changed packages, got %v", artifacts.PackageRunnerStats.PackageUsage)
 	}
-	agent.LLM = llm
+}
 
-	summarizeService := &summarize.SummarizeService{
-		Agent: agent,
-		LlmOptions: []llms.CallOption{
-			llms.WithStopWords(
-				projectConfig.StopWords,
-			),
-			llms.WithRepetitionPenalty(0.7),
+func TestReflexiaAllProjectsRun(t *testing.T) {
+	workdir := newTestProject(t)
+	for path, content := range map[string]string{
+		"web/package.json":      `{"name": "web"}`,
+		"web/src/index.ts":      "export function main() {}\n",
+		"web/src/lib/format.ts": "export function format() {}\n",
+	} {
+		path = filepath.Join(workdir, path)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reflexiaCall := reflexia.ReflexiaCall{
+		LocalWorkdir: workdir,
+		AllProjects:  true,
+		Config: config.Config{
+			CacheBackend: cache.BackendMemory,
+			LLMProvider:  provider.Fake,
 		},
-		IgnoreCache: true,
-		StopWords:   projectConfig.StopWords,
-		Model:       agentCfg.Model,
-	}
-	t.Run(
-		"Test LLM response",
-		func(t *testing.T) {
-			testPrompt := "This is a test prompt. Write a hex notation of red color in reply."
-			resp, err := summarizeService.LLMRequest(testPrompt)
-			if err != nil {
-				t.Fatal(err)
-			}
-			if resp == "" {
-				t.Fatal("Empty output from LLM")
-			}
+		AgentConfig: agentConfig.Config{Model: provider.Fake},
+		PrintTo:     io.Discard,
+	}
+
+	artifacts, err := reflexiaCall.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects := []string{}
+	for _, project := range artifacts.Projects {
+		projects = append(projects, project.Project)
+	}
+	if !slices.Equal(projects, []string{"go.toml", "web/ts.toml"}) {
+		t.Fatalf("unexpected projects %v", projects)
+	}
+	for _, pkg := range []string{"go.toml:.:main", "web/ts.toml:src/lib"} {
+		if _, ok := artifacts.PackageRunnerStats.PackageUsage[pkg]; !ok {
+			t.Fatalf("no %s package in the combined stats %v", pkg, artifacts.PackageRunnerStats.PackageUsage)
+		}
+	}
+	if _, ok := artifacts.PackageRunnerStats.FileUsage["web/src/index.ts"]; !ok {
+		t.Fatalf("no workdir relative file usage in the combined stats %v", artifacts.PackageRunnerStats.FileUsage)
+	}
+	for _, path := range []string{"README.md", "web/README.md", "web/src/lib/README.md"} {
+		if _, err := os.Stat(filepath.Join(workdir, path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReflexiaOutputRun(t *testing.T) {
+	workdir := newTestProject(t)
+	outputRoot := filepath.Join(t.TempDir(), "docs")
+
+	reflexiaCall := reflexia.ReflexiaCall{
+		LocalWorkdir:    workdir,
+		WithConfigFile:  "go.toml",
+		WithFileSummary: true,
+		OutputRoot:      outputRoot,
+		Config: config.Config{
+			CacheBackend: cache.BackendMemory,
+			LLMProvider:  provider.Fake,
 		},
-	)
+		AgentConfig: agentConfig.Config{Model: provider.Fake},
+		ChooserFunc: project.FirstChooser,
+		PrintTo:     io.Discard,
+	}
+
+	artifacts, err := reflexiaCall.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artifacts.PackageRunnerStats.ProjectReadme != filepath.Join(outputRoot, "README.md") {
+		t.Fatalf("unexpected project README %s", artifacts.PackageRunnerStats.ProjectReadme)
+	}
+	for _, path := range []string{
+		"README.md",
+		"internal/greeter/README.md",
+		"internal/greeter/FILES.md",
+	} {
+		if _, err := os.Stat(filepath.Join(outputRoot, path)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(workdir, path)); err == nil {
+			t.Fatalf("%s is written in place", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputRoot, reflexia.ManifestFilename)); err == nil {
+		t.Fatal("manifest is written by the non-incremental run")
+	}
+
+	// The readmes of the previous run in the output root are overwritten
+	if _, err := reflexiaCall.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outputRoot, "internal/greeter/README_GENERATED.md")); err == nil {
+		t.Fatal("generated readme is written next to the readme of the previous run")
+	}
+
+	memoryOutput := packagerunner.NewMemoryOutput()
+	reflexiaCall.Output = memoryOutput
+	if _, err := reflexiaCall.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(memoryOutput.Names(), []string{
+		"FILES.md",
+		"README.md",
+		"internal/greeter/FILES.md",
+		"internal/greeter/README.md",
+	}) {
+		t.Fatalf("unexpected memory output %v", memoryOutput.Names())
+	}
+	content, err := fs.ReadFile(memoryOutput.FS(), "internal/greeter/README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "Fake summary") ||
+		!strings.HasSuffix(string(content), "\n\n## Used by\n\n- [.:main](../../README.md)\n") {
+		t.Fatalf("unexpected greeter README:\n%s", content)
+	}
+
+	reflexiaCall.CreatePR = true
+	if _, err := reflexiaCall.Run(context.Background()); err == nil {
+		t.Fatal("expected the pull request error for the separate output")
+	}
+}
+
+func TestReflexiaOutputRunSharedDirectory(t *testing.T) {
+	workdir := newTestProject(t)
+	if err := os.WriteFile(
+		filepath.Join(workdir, "internal/greeter/greeter_test.go"),
+		[]byte("package greeter_test\n\nfunc ExampleGreet() {}\n"), 0644,
+	); err != nil {
+		t.Fatal(err)
+	}
+	outputRoot := filepath.Join(t.TempDir(), "docs")
+
+	reflexiaCall := reflexia.ReflexiaCall{
+		LocalWorkdir:   workdir,
+		WithConfigFile: "go.toml",
+		OutputRoot:     outputRoot,
+		Parallelism:    4,
+		Config: config.Config{
+			CacheBackend: cache.BackendMemory,
+			LLMProvider:  provider.Fake,
+		},
+		AgentConfig: agentConfig.Config{Model: provider.Fake},
+		ChooserFunc: project.FirstChooser,
+		PrintTo:     io.Discard,
+	}
+
+	// The packages of the same directory keep their distinct readmes on the reruns into the output root
+	for range 3 {
+		artifacts, err := reflexiaCall.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs := artifacts.PackageRunnerStats.PackageOutputs
+		if !slices.Equal(outputs["internal/greeter:greeter"], []string{
+			filepath.Join(outputRoot, "internal/greeter/README.md"),
+		}) || !slices.Equal(outputs["internal/greeter:greeter_test"], []string{
+			filepath.Join(outputRoot, "internal/greeter/README_GENERATED.md"),
+		}) {
+			t.Fatalf("unexpected package outputs %v", outputs)
+		}
+		if _, err := os.Stat(filepath.Join(outputRoot, "internal/greeter/README_GENERATED_2.md")); err == nil {
+			t.Fatal("readme of the previous run is not overwritten")
+		}
+	}
+	readme, err := os.ReadFile(filepath.Join(outputRoot, "internal/greeter/README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated, err := os.ReadFile(filepath.Join(outputRoot, "internal/greeter/README_GENERATED.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(readme, generated) {
+		t.Fatalf("greeter_test summary overwrites the greeter one:\n%s", readme)
+	}
+}
+
+func TestReflexiaReport(t *testing.T) {
+	workdir := newTestProject(t)
+
+	reflexiaCall := reflexia.ReflexiaCall{
+		LocalWorkdir:   workdir,
+		WithConfigFile: "go.toml",
+		Config: config.Config{
+			CacheBackend: cache.BackendMemory,
+			LLMProvider:  provider.Fake,
+			LLMProviderSettings: provider.Settings{
+				FakeRules: []provider.FakeRule{{Match: "func Broken", Empty: true}},
+			},
+		},
+		AgentConfig: agentConfig.Config{Model: provider.Fake},
+		ChooserFunc: project.FirstChooser,
+		PrintTo:     io.Discard,
+	}
+	artifacts, err := reflexiaCall.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := reflexia.NewReport(artifacts)
+	if len(report.Projects) != 1 || report.Projects[0].Config.Name != "go.toml" ||
+		report.Projects[0].Readme != filepath.Join(workdir, "README.md") {
+		t.Fatalf("unexpected report projects %+v", report.Projects)
+	}
+	packages := report.Projects[0].Packages
+	if len(packages) != 2 || packages[1].Key != "internal/greeter:greeter" || packages[1].Dir != "internal/greeter" {
+		t.Fatalf("unexpected report packages %+v", packages)
+	}
+	greeter := packages[1]
+	if !slices.Equal(greeter.Outputs, []string{filepath.Join(workdir, "internal/greeter/README.md")}) ||
+		!strings.Contains(greeter.Summary, "Fake summary") || greeter.Usage == nil {
+		t.Errorf("unexpected greeter package report %+v", greeter)
+	}
+	for _, file := range greeter.Files {
+		if file.Empty != (file.Path == "internal/greeter/broken.go") {
+			t.Errorf("unexpected %s empty flag", file.Path)
+		}
+	}
+
+	reportPath := filepath.Join(t.TempDir(), "report.jsonl")
+	if err := writeReport(reportPath, report); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var record struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, record.Type)
+	}
+	if !slices.Equal(types, []string{"package", "package", "project", "run"}) {
+		t.Fatalf("unexpected JSON lines record types %v", types)
+	}
+
+	// The streamed records are the same as the ones written after the run
+	streamed := &bytes.Buffer{}
+	reflexiaCall.ReportTo = streamed
+	if _, err := reflexiaCall.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	streamedTypes := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(streamed.String()), "\n") {
+		var record struct {
+			Type string `json:"type"`
+			Key  string `json:"key"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		streamedTypes = append(streamedTypes, record.Type+":"+record.Key)
+	}
+	if !slices.Equal(streamedTypes, []string{
+		"package:.:main", "package:internal/greeter:greeter", "project:", "run:",
+	}) {
+		t.Fatalf("unexpected streamed records %v", streamedTypes)
+	}
 }
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/JackBekket/reflexia/pkg/project"
+	projectconfig "github.com/JackBekket/reflexia/project_config"
+)
+
+const configsUsage = `Usage: reflexia configs <command> [flags] [args]
+
+Commands:
+  list [workdir]         list project configs of the search path and where they are loaded from
+  show <name> [workdir]  print the project config, a name or a toml file path,
+                         merged with its extends and include configs
+  validate [workdir]     report the problems of every project config with the file positions
+  schema                 print the JSON Schema of the project config files for the editors
+
+Project configs are searched in the order of increasing precedence:
+embedded defaults, system, user config dir, ./project_config, <workdir>/.reflexia/project_config
+and the explicit -l file.
+
+Run reflexia configs <command> -h for the command flags.
+`
+
+func runConfigsCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Print(configsUsage)
+		return errors.New("configs command is required")
+	}
+	command, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("configs "+command, flag.ExitOnError)
+	withConfigFile := flags.String("l", "", "explicit project config file path")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	name := ""
+	if command == "show" {
+		if len(args) == 0 {
+			fmt.Print(configsUsage)
+			return errors.New("config name is required")
+		}
+		name, args = args[0], args[1:]
+	}
+	workdir := ""
+	if len(args) > 0 {
+		workdir = args[0]
+	}
+	if workdir == "" {
+		var err error
+		if workdir, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+
+	switch command {
+	case "schema":
+		_, err := os.Stdout.Write(projectconfig.Schema)
+		return err
+
+	case "validate":
+		problems, err := project.ValidateProjectConfigs(workdir, *withConfigFile)
+		if err != nil {
+			return err
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("found %d project config problems", len(problems))
+		}
+		fmt.Println("Project configs are valid")
+		return nil
+
+	case "list":
+		files, err := project.ListProjectConfigSources(workdir, *withConfigFile)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSOURCE\tPATH\t")
+		for _, file := range files {
+			status := ""
+			switch {
+			case file.Overridden:
+				status = "overridden"
+			case file.Partial:
+				status = "partial"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", file.Name, file.Source, file.Path, status)
+		}
+		return w.Flush()
+
+	case "show":
+		if filepath.Ext(name) != ".toml" {
+			name += ".toml"
+		}
+		configs, err := project.GetProjectConfig(workdir, name, true)
+		if err != nil {
+			return err
+		}
+		config := configs[filepath.Base(name)]
+		content, err := config.MarshalMerged()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# %s (%s)\n", config.Path, config.Source)
+		for _, path := range config.Inherits {
+			fmt.Printf("# inherits %s\n", path)
+		}
+		fmt.Printf("\n%s", content)
+		return nil
+
+	default:
+		fmt.Print(configsUsage)
+		return fmt.Errorf("unknown configs command %s", command)
+	}
+}
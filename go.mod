@@ -18,7 +18,11 @@ require (
 	github.com/swaggest/swgui v1.8.4
 	github.com/swaggest/usecase v1.3.1
 	github.com/tmc/langchaingo v0.1.13
+	go.etcd.io/bbolt v1.4.3
 	golang.org/x/oauth2 v0.21.0
+	golang.org/x/sync v0.13.0
+	golang.org/x/time v0.5.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -61,9 +65,7 @@ require (
 	github.com/xanzy/ssh-agent v0.3.3 // indirect
 	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/net v0.39.0 // indirect
-	golang.org/x/sync v0.13.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
 	gopkg.in/warnings.v0 v0.1.2 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
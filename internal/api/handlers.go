@@ -1,27 +1,41 @@
 package api
 
 import (
+	"archive/zip"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"slices"
 
+	"github.com/JackBekket/reflexia/pkg/cache"
 	"github.com/JackBekket/reflexia/pkg/config"
+	packagerunner "github.com/JackBekket/reflexia/pkg/package_runner"
 	"github.com/JackBekket/reflexia/pkg/project"
+	"github.com/JackBekket/reflexia/pkg/provider"
 	"github.com/JackBekket/reflexia/pkg/reflexia"
+	"github.com/JackBekket/reflexia/pkg/site"
+	projectconfig "github.com/JackBekket/reflexia/project_config"
 	agentConfig "github.com/Swarmind/libagent/pkg/config"
+	"github.com/rs/zerolog/log"
 	"github.com/swaggest/usecase/status"
 )
 
 type APIService struct {
 	Workdir string
 	Config  config.Config
+	Cache   cache.Cache
 }
 
 type ReflectInput struct {
 	AIURL   string `json:"ai_url"`
 	AIToken string `json:"ai_token"`
 	Model   string `json:"model"`
+	// Provider is openai, ollama or anthropic, server default is used if empty
+	Provider         string             `json:"provider,omitempty"`
+	ProviderSettings *provider.Settings `json:"provider_settings,omitempty"`
 
 	RepositoryURL    string `json:"repository_url"`
 	RepositoryBranch string `json:"repository_branch,omitempty"`
@@ -38,23 +52,104 @@ type ReflectInput struct {
 
 	OverwriteCache bool `json:"overwrite_cache,omitempty"`
 	UseEmbeddings  bool `json:"use_embeddings,omitempty"`
+	// Incremental of the remote runs compares with the manifest of the base branch,
+	// which has none until the documentation pull request is merged
+	Incremental bool `json:"incremental,omitempty"`
+	AllProjects bool `json:"all_projects,omitempty"`
+	// ReturnFiles keeps the generated files in memory and returns them instead of writing them into the clone
+	ReturnFiles bool `json:"return_files,omitempty"`
+	// Report returns the machine-readable report of the run
+	Report bool `json:"report,omitempty"`
+	// SiteFormat is the /reflect/site layout: html (default), mkdocs or docusaurus
+	SiteFormat string `json:"site_format,omitempty"`
+
+	// Parallelism is capped at MaxParallelism
+	Parallelism int `json:"parallelism,omitempty"`
 }
 
+// MaxParallelism caps the parallelism input of a single API request
+const MaxParallelism = 8
+
 type ReflectOutput struct {
-	PullRequestURL string `json:"pull_request_url"`
+	PullRequestURL string              `json:"pull_request_url"`
+	Usage          packagerunner.Usage `json:"usage"`
+	Retries        int                 `json:"retries"`
+	// UnchangedPackages are skipped by the incremental run
+	UnchangedPackages []string `json:"unchanged_packages,omitempty"`
+	// ConfigRanking explains the project config choice among several candidates
+	ConfigRanking []project.ConfigScore `json:"config_ranking,omitempty"`
+	// Files maps the repository relative paths of the generated files to their content if return_files is set
+	Files map[string]string `json:"files,omitempty"`
+	// Report is returned if the report input is set
+	Report *reflexia.Report `json:"report,omitempty"`
 }
 
 func (s APIService) ReflectPost(ctx context.Context,
 	input ReflectInput,
 	output *ReflectOutput,
 ) error {
+	reflexiaCall, err := s.newReflexiaCall(input)
+	if err != nil {
+		return err
+	}
+
+	artifacts, err := reflexiaCall.Run(ctx)
+	if err != nil {
+		return status.Wrap(fmt.Errorf("reflexia call: %w", err), status.Internal)
+	}
+
+	*output = ReflectOutput{
+		Usage:   artifacts.PackageRunnerStats.Usage,
+		Retries: artifacts.PackageRunnerStats.Retries,
+
+		UnchangedPackages: artifacts.UnchangedPackages,
+		ConfigRanking:     artifacts.ConfigRanking,
+	}
+	if artifacts.PullRequestURL != nil {
+		output.PullRequestURL = *artifacts.PullRequestURL
+	}
+	if input.Report {
+		report := reflexia.NewReport(artifacts)
+		output.Report = &report
+	}
+	if memoryOutput, ok := reflexiaCall.Output.(*packagerunner.MemoryOutput); ok {
+		output.Files = map[string]string{}
+		for _, name := range memoryOutput.Names() {
+			content, err := memoryOutput.ReadFile(name)
+			if err != nil {
+				return status.Wrap(fmt.Errorf("read generated %s: %w", name, err), status.Internal)
+			}
+			output.Files[name] = string(content)
+		}
+	}
+
+	return nil
+}
+
+// newReflexiaCall validates the input and returns the call of the repository documentation
+func (s APIService) newReflexiaCall(input ReflectInput) (reflexia.ReflexiaCall, error) {
 	if input.RepositoryURL == "" {
-		return status.Wrap(errors.New("empty repository_url"), status.InvalidArgument)
+		return reflexia.ReflexiaCall{}, status.Wrap(errors.New("empty repository_url"), status.InvalidArgument)
+	}
+	if input.ReturnFiles && input.CreatePR {
+		return reflexia.ReflexiaCall{}, status.Wrap(errors.New("return_files and create_pr are exclusive"), status.InvalidArgument)
+	}
+	if input.SiteFormat != "" && !slices.Contains(site.Formats, input.SiteFormat) {
+		return reflexia.ReflexiaCall{}, status.Wrap(fmt.Errorf("unknown site_format %q", input.SiteFormat), status.InvalidArgument)
+	}
+
+	cfg := s.Config
+	if input.Provider != "" {
+		cfg.LLMProvider = input.Provider
+	}
+	if input.ProviderSettings != nil {
+		cfg.LLMProviderSettings = *input.ProviderSettings
 	}
 
 	reflexiaCall := reflexia.ReflexiaCall{
-		Config:      s.Config,
-		ChooserFunc: project.FirstChooser,
+		Config:      cfg,
+		Cache:       s.Cache,
+		ChooserFunc: project.ScoreChooser,
 		PrintTo:     io.Discard,
 
 		AgentConfig: agentConfig.Config{
@@ -75,25 +170,58 @@ func (s APIService) ReflectPost(ctx context.Context,
 		UseEmbeddings:    input.UseEmbeddings,
 		OverwriteReadme:  input.OverwriteReadme,
 		OverwriteCache:   input.OverwriteCache,
+		Parallelism:      min(input.Parallelism, MaxParallelism),
+		Incremental:      input.Incremental,
+		AllProjects:      input.AllProjects,
+		SiteFormat:       input.SiteFormat,
+	}
+	if input.ReturnFiles {
+		reflexiaCall.Output = packagerunner.NewMemoryOutput()
 	}
+	return reflexiaCall, nil
+}
 
-	artifacts, err := reflexiaCall.Run(ctx)
+// ReflectSitePost documents the repository like ReflectPost and responds with the zip archive
+// of the static HTML documentation site, or of the MkDocs or Docusaurus docs tree by the site_format
+func (s APIService) ReflectSitePost(w http.ResponseWriter, r *http.Request) {
+	var input ReflectInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("decode input: %v", err), http.StatusBadRequest)
+		return
+	}
+	reflexiaCall, err := s.newReflexiaCall(input)
 	if err != nil {
-		return status.Wrap(fmt.Errorf("reflexia call: %w", err), status.Internal)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-
-	if artifacts.PullRequestURL != nil {
-		*output = ReflectOutput{
-			PullRequestURL: *artifacts.PullRequestURL,
-		}
+	siteOutput := packagerunner.NewMemoryOutput()
+	reflexiaCall.SiteOutput = siteOutput
+	if _, err := reflexiaCall.Run(r.Context()); err != nil {
+		http.Error(w, fmt.Sprintf("reflexia call: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	return nil
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="site.zip"`)
+	archive := zip.NewWriter(w)
+	if err := archive.AddFS(siteOutput.FS()); err != nil {
+		log.Warn().Err(err).Msg("write site archive")
+		return
+	}
+	if err := archive.Close(); err != nil {
+		log.Warn().Err(err).Msg("close site archive")
+	}
 }
 
 type ProjectConfig struct {
 	FileFilter        []string `json:"file_filter"`
 	ProjectRootFilter []string `json:"project_root_filter"`
+	ModuleMatch       string   `json:"module_match"`
+	// Source is the search path source the config is loaded from
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	// Inherits are the extended and included config paths in the merge order
+	Inherits []string `json:"inherits,omitempty"`
 }
 
 func (s APIService) ProjectConfigsGet(ctx context.Context,
@@ -110,9 +238,59 @@ func (s APIService) ProjectConfigsGet(ctx context.Context,
 		resp[k] = ProjectConfig{
 			FileFilter:        v.FileFilter,
 			ProjectRootFilter: v.ProjectRootFilter,
+			ModuleMatch:       v.ModuleMatch,
+			Source:            v.Source,
+			Path:              v.Path,
+			Inherits:          v.Inherits,
 		}
 	}
 	*output = resp
 
 	return nil
 }
+
+type ProjectConfigsValidateInput struct {
+	// Name and Content validate the uploaded toml config as the -l config,
+	// the search path configs are validated if Content is empty
+	Name    string `json:"name,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type ProjectConfigsValidateOutput struct {
+	Valid    bool                      `json:"valid"`
+	Problems []project.ValidationError `json:"problems"`
+}
+
+func (s APIService) ProjectConfigsValidatePost(ctx context.Context,
+	input ProjectConfigsValidateInput,
+	output *ProjectConfigsValidateOutput,
+) error {
+	var (
+		problems []project.ValidationError
+		err      error
+	)
+	if input.Content == "" {
+		problems, err = project.ValidateProjectConfigs(s.Workdir, "")
+	} else {
+		name := input.Name
+		if name == "" {
+			name = "config.toml"
+		}
+		problems, err = project.ValidateProjectConfigContent(s.Workdir, name, []byte(input.Content))
+	}
+	if err != nil {
+		return status.Wrap(fmt.Errorf("validating project configs: %w", err), status.Internal)
+	}
+
+	output.Valid = len(problems) == 0
+	output.Problems = problems
+	return nil
+}
+
+// ProjectConfigSchemaGet serves the JSON Schema of the project config files
+func (s APIService) ProjectConfigSchemaGet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	if _, err := w.Write(projectconfig.Schema); err != nil {
+		log.Warn().Err(err).Msg("write project config schema")
+	}
+}
@@ -0,0 +1,62 @@
+package github
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// HeadCommit returns the hash of the repository HEAD commit
+func HeadCommit(repo *git.Repository) (string, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("get head: %w", err)
+	}
+	return head.Hash().String(), nil
+}
+
+// ChangedFiles returns the paths relative to the repository root
+// of the files added, modified, renamed or deleted between the from commit and HEAD.
+// The from commit has to be present in the repository history,
+// which is usually not the case for the shallow clones
+func ChangedFiles(repo *git.Repository, from string) ([]string, error) {
+	fromCommit, err := repo.CommitObject(plumbing.NewHash(from))
+	if err != nil {
+		return nil, fmt.Errorf("get commit %s: %w", from, err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("get head: %w", err)
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("get head commit: %w", err)
+	}
+
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("get commit %s tree: %w", from, err)
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("get head commit tree: %w", err)
+	}
+
+	changes, err := object.DiffTree(fromTree, headTree)
+	if err != nil {
+		return nil, fmt.Errorf("diff trees: %w", err)
+	}
+
+	paths := []string{}
+	for _, change := range changes {
+		if change.From.Name != "" {
+			paths = append(paths, change.From.Name)
+		}
+		if change.To.Name != "" && change.To.Name != change.From.Name {
+			paths = append(paths, change.To.Name)
+		}
+	}
+	return paths, nil
+}
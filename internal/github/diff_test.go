@@ -0,0 +1,81 @@
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(files map[string]string, removed ...string) string {
+		for path, content := range files {
+			if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, path := range removed {
+			if _, err := wt.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := wt.AddGlob("."); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := wt.Commit("commit", &git.CommitOptions{
+			Author: &object.Signature{Name: CommitName, Email: CommitEmail, When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash.String()
+	}
+
+	from := commit(map[string]string{
+		"main.go":    "package main",
+		"pkg/a/a.go": "package a",
+		"pkg/b/b.go": "package b",
+		"pkg/c/c.go": "package c",
+	})
+	commit(map[string]string{
+		"pkg/a/a.go": "package a\n\nfunc A() {}",
+		"pkg/d/d.go": "package d",
+	}, "pkg/c/c.go")
+
+	head, err := HeadCommit(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head == from {
+		t.Fatal("head is not updated")
+	}
+
+	paths, err := ChangedFiles(repo, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(paths)
+	if !slices.Equal(paths, []string{"pkg/a/a.go", "pkg/c/c.go", "pkg/d/d.go"}) {
+		t.Fatalf("unexpected changed files %v", paths)
+	}
+
+	if _, err := ChangedFiles(repo, "0123456789012345678901234567890123456789"); err == nil {
+		t.Fatal("expected an error for the missing commit")
+	}
+}
@@ -207,3 +207,23 @@ func GithubWorkdir(
 
 	return workdir, repo, branch, cancelFunc, nil
 }
+
+// BlobURL returns the base URL of the repository files of the branch,
+// including the subdirectory of the extended repository url, e.g.
+// https://github.com/owner/repo/blob/main/backend/ for https://github.com/owner/repo/tree/main/backend
+func BlobURL(repoURL, branch string) (string, error) {
+	u, err := url.ParseRequestURI(repoURL)
+	if err != nil {
+		return "", err
+	}
+	sPath := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(sPath) < 2 {
+		return "", errors.New("github repository url does not have at least two path elements")
+	}
+	subdir := []string{}
+	if len(sPath) > 4 && sPath[2] == "tree" {
+		subdir = sPath[4:]
+	}
+	u.Path = "/" + strings.Join(append([]string{sPath[0], strings.TrimSuffix(sPath[1], ".git"), "blob", branch}, subdir...), "/") + "/"
+	return u.String(), nil
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const boltFilename = "cache.db"
+
+var (
+	boltBucket         = []byte("responses")
+	boltMetadataBucket = []byte("metadata")
+)
+
+// BoltCache stores all of the entries in a single embedded key/value database file
+type BoltCache struct {
+	db *bolt.DB
+}
+
+// NewBoltCache opens the cache.db database of the cache directory, creating the directory if needed
+func NewBoltCache(dir string) (*BoltCache, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, boltFilename)
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt db %s: %w", path, err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{boltBucket, boltMetadataBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create bolt buckets: %w", err)
+	}
+
+	return &BoltCache{db: db}, nil
+}
+
+func (c *BoltCache) Load(key string) (Entry, error) {
+	entry := Entry{}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltBucket).Get([]byte(key))
+		if value == nil {
+			return ErrNotFound
+		}
+		var err error
+		entry, err = boltEntry(tx, []byte(key), value)
+		entry.Content = string(value)
+		return err
+	})
+	return entry, err
+}
+
+func (c *BoltCache) Save(entry Entry) error {
+	metadata, err := json.Marshal(entry.Metadata)
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltBucket).Put([]byte(entry.Key), []byte(entry.Content)); err != nil {
+			return err
+		}
+		return tx.Bucket(boltMetadataBucket).Put([]byte(entry.Key), metadata)
+	})
+}
+
+func (c *BoltCache) Delete(key string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltBucket).Get([]byte(key)) == nil {
+			return ErrNotFound
+		}
+		if err := tx.Bucket(boltBucket).Delete([]byte(key)); err != nil {
+			return err
+		}
+		return tx.Bucket(boltMetadataBucket).Delete([]byte(key))
+	})
+}
+
+func (c *BoltCache) List() ([]Entry, error) {
+	entries := []Entry{}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).ForEach(func(key, value []byte) error {
+			entry, err := boltEntry(tx, key, value)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	return entries, err
+}
+
+func (c *BoltCache) Close() error {
+	return c.db.Close()
+}
+
+func boltEntry(tx *bolt.Tx, key, value []byte) (Entry, error) {
+	entry := Entry{
+		Key:  string(key),
+		Size: int64(len(value)),
+	}
+	metadata := tx.Bucket(boltMetadataBucket).Get(key)
+	if metadata == nil {
+		return entry, nil
+	}
+	entry.Size += int64(len(metadata))
+	if err := json.Unmarshal(metadata, &entry.Metadata); err != nil {
+		return Entry{}, fmt.Errorf("unmarshal %s metadata: %w", key, err)
+	}
+	return entry, nil
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+const (
+	BackendFilesystem = "fs"
+	BackendBolt       = "bolt"
+	BackendMemory     = "memory"
+)
+
+var ErrNotFound = fmt.Errorf("cache entry %w", fs.ErrNotExist)
+
+// Metadata describes which request produced the cached response,
+// entries written before metadata introduction have only CreatedAt set
+type Metadata struct {
+	Model          string    `json:"model,omitempty"`
+	Project        string    `json:"project,omitempty"`
+	ProjectConfig  string    `json:"project_config,omitempty"`
+	Prompts        string    `json:"prompts,omitempty"`
+	Package        string    `json:"package,omitempty"`
+	SourcePath     string    `json:"source_path,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	PromptBytes    int       `json:"prompt_bytes,omitempty"`
+	PromptTokens   int       `json:"prompt_tokens,omitempty"`
+	ResponseBytes  int       `json:"response_bytes,omitempty"`
+	ResponseTokens int       `json:"response_tokens,omitempty"`
+}
+
+type Entry struct {
+	Key      string   `json:"key"`
+	Content  string   `json:"content,omitempty"`
+	Metadata Metadata `json:"metadata"`
+	// Size is the stored entry size in bytes, filled by Load and List
+	Size int64 `json:"size"`
+}
+
+// Cache stores LLM responses by the request hash.
+// Load and Delete return ErrNotFound for the missing entries,
+// List returns entries without Content sorted by key
+type Cache interface {
+	Load(key string) (Entry, error)
+	Save(entry Entry) error
+	Delete(key string) error
+	List() ([]Entry, error)
+	Close() error
+}
+
+// Open creates the cache of the given backend type,
+// path is the cache directory for the fs backend and for the bolt backend,
+// which keeps its cache.db database there, and is ignored for the memory backend
+func Open(backend, path string) (Cache, error) {
+	switch backend {
+	case BackendFilesystem, "":
+		return NewFilesystemCache(path), nil
+	case BackendBolt:
+		return NewBoltCache(path)
+	case BackendMemory:
+		return NewMemoryCache(), nil
+	default:
+		return nil, fmt.Errorf(
+			"unknown cache backend %s, available backends: %s, %s, %s",
+			backend, BackendFilesystem, BackendBolt, BackendMemory,
+		)
+	}
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackends(t *testing.T) {
+	for _, backend := range []string{BackendFilesystem, BackendBolt, BackendMemory} {
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache")
+			c, err := Open(backend, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if _, err := os.Stat(filepath.Join(path, boltFilename)); backend == BackendBolt && err != nil {
+				t.Fatalf("expected the bolt database in the cache directory: %v", err)
+			}
+
+			if _, err := c.Load("missing"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected ErrNotFound for a missing entry, got %v", err)
+			}
+
+			old := time.Now().Add(-48 * time.Hour)
+			for key, metadata := range map[string]Metadata{
+				"fresh": {Model: "model-a", SourcePath: "pkg/a/a.go", CreatedAt: time.Now()},
+				"old":   {Model: "model-b", SourcePath: "pkg/b/b.go", CreatedAt: old},
+			} {
+				if err := c.Save(Entry{Key: key, Content: "content " + key, Metadata: metadata}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			entry, err := c.Load("fresh")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Content != "content fresh" || entry.Metadata.Model != "model-a" {
+				t.Fatalf("unexpected loaded entry %+v", entry)
+			}
+
+			if entry, err := LoadPrefix(c, "fr"); err != nil || entry.Key != "fresh" {
+				t.Fatalf("expected the fresh entry by the key prefix, got %+v, %v", entry, err)
+			}
+			if _, err := LoadPrefix(c, "x"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected ErrNotFound for an unknown prefix, got %v", err)
+			}
+
+			found, err := Find(c, Filter{Query: "PKG/B"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found) != 1 || found[0].Key != "old" {
+				t.Fatalf("expected search to find the old entry, got %+v", found)
+			}
+
+			pruned, err := Prune(c, Filter{OlderThan: 24 * time.Hour})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pruned) != 1 || pruned[0].Key != "old" {
+				t.Fatalf("expected the old entry to be pruned, got %+v", pruned)
+			}
+			entries, err := c.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Key != "fresh" || entries[0].Size == 0 {
+				t.Fatalf("expected only the fresh entry left, got %+v", entries)
+			}
+		})
+	}
+}
+
+func TestFilesystemCacheConcurrentSave(t *testing.T) {
+	c := NewFilesystemCache(t.TempDir())
+	contents := []string{strings.Repeat("a", 1<<16), strings.Repeat("b", 1<<16)}
+	if err := c.Save(Entry{Key: "key", Content: contents[0]}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := c.Save(Entry{Key: "key", Content: contents[i%2]}); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			entry, err := c.Load("key")
+			if err != nil {
+				t.Error(err)
+			} else if entry.Content != contents[0] && entry.Content != contents[1] {
+				t.Errorf("partial entry of %d bytes is loaded", len(entry.Content))
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry without the temp files, got %+v", entries)
+	}
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const metadataSuffix = ".json"
+
+// FilesystemCache stores every entry in a separate file named by its key
+// with the metadata in a .json suffixed file next to it
+type FilesystemCache struct {
+	Path string
+}
+
+func NewFilesystemCache(path string) *FilesystemCache {
+	return &FilesystemCache{Path: path}
+}
+
+func (c *FilesystemCache) Load(key string) (Entry, error) {
+	content, err := os.ReadFile(filepath.Join(c.Path, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Entry{}, ErrNotFound
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	entry, err := c.entry(key)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.Content = string(content)
+	return entry, nil
+}
+
+func (c *FilesystemCache) Save(entry Entry) error {
+	if err := os.MkdirAll(c.Path, os.ModePerm); err != nil {
+		return err
+	}
+	metadata, err := json.Marshal(entry.Metadata)
+	if err != nil {
+		return err
+	}
+	// The metadata goes first so the loaded content always has its metadata
+	if err := writeFile(filepath.Join(c.Path, entry.Key+metadataSuffix), string(metadata)); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(c.Path, entry.Key), entry.Content)
+}
+
+func (c *FilesystemCache) Delete(key string) error {
+	err := os.Remove(filepath.Join(c.Path, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(c.Path, key+metadataSuffix))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (c *FilesystemCache) List() ([]Entry, error) {
+	dirEntries, err := os.ReadDir(c.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || strings.HasSuffix(dirEntry.Name(), metadataSuffix) ||
+			strings.HasPrefix(dirEntry.Name(), tempPrefix) {
+			continue
+		}
+		entry, err := c.entry(dirEntry.Name())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return entries, nil
+}
+
+func (c *FilesystemCache) Close() error {
+	return nil
+}
+
+// entry reads the entry metadata and size without the content
+func (c *FilesystemCache) entry(key string) (Entry, error) {
+	info, err := os.Stat(filepath.Join(c.Path, key))
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{
+		Key:  key,
+		Size: info.Size(),
+	}
+
+	metadata, err := os.ReadFile(filepath.Join(c.Path, key+metadataSuffix))
+	if errors.Is(err, fs.ErrNotExist) {
+		entry.Metadata.CreatedAt = info.ModTime()
+		return entry, nil
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.Size += int64(len(metadata))
+	if err := json.Unmarshal(metadata, &entry.Metadata); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// tempPrefix marks the files being written, they are not listed as the entries
+const tempPrefix = ".tmp-"
+
+// writeFile writes the content into a temp file of the same directory and renames it into place,
+// so the concurrent Load never reads a partial entry
+func writeFile(path, content string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), tempPrefix+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
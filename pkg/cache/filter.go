@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Filter matches the entries by all of its non-empty fields
+type Filter struct {
+	OlderThan     time.Duration
+	Model         string
+	Project       string
+	ProjectConfig string
+	// Query is a case insensitive substring of the source path, package,
+	// project, project config or model
+	Query string
+}
+
+func (f Filter) Match(entry Entry, now time.Time) bool {
+	metadata := entry.Metadata
+	if f.OlderThan > 0 && now.Sub(metadata.CreatedAt) < f.OlderThan {
+		return false
+	}
+	if f.Model != "" && metadata.Model != f.Model {
+		return false
+	}
+	if f.Project != "" && metadata.Project != f.Project {
+		return false
+	}
+	if f.ProjectConfig != "" && metadata.ProjectConfig != f.ProjectConfig {
+		return false
+	}
+	if f.Query != "" {
+		query := strings.ToLower(f.Query)
+		for _, field := range []string{
+			metadata.SourcePath,
+			metadata.Package,
+			metadata.Project,
+			metadata.ProjectConfig,
+			metadata.Model,
+		} {
+			if strings.Contains(strings.ToLower(field), query) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func Find(c Cache, filter Filter) ([]Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	found := []Entry{}
+	for _, entry := range entries {
+		if filter.Match(entry, now) {
+			found = append(found, entry)
+		}
+	}
+	return found, nil
+}
+
+// Prune deletes the entries matching the filter and returns them
+func Prune(c Cache, filter Filter) ([]Entry, error) {
+	found, err := Find(c, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range found {
+		if err := c.Delete(entry.Key); err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// LoadPrefix loads the entry of the exact key, or of the only key starting with the prefix,
+// such as the shortened keys printed by the cache list command
+func LoadPrefix(c Cache, prefix string) (Entry, error) {
+	entry, err := c.Load(prefix)
+	if !errors.Is(err, ErrNotFound) || prefix == "" {
+		return entry, err
+	}
+	entries, err := c.List()
+	if err != nil {
+		return Entry{}, err
+	}
+	keys := []string{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Key, prefix) {
+			keys = append(keys, entry.Key)
+		}
+	}
+	switch len(keys) {
+	case 0:
+		return Entry{}, ErrNotFound
+	case 1:
+		return c.Load(keys[0])
+	}
+	return Entry{}, fmt.Errorf("key prefix %s is ambiguous, it matches %d entries", prefix, len(keys))
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MemoryCache keeps the entries for the process lifetime only, mostly useful for tests
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]Entry{}}
+}
+
+func (c *MemoryCache) Load(key string) (Entry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return entry, nil
+}
+
+func (c *MemoryCache) Save(entry Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.Size = int64(len(entry.Content))
+	c.entries[entry.Key] = entry
+	return nil
+}
+
+func (c *MemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		return ErrNotFound
+	}
+	delete(c.entries, key)
+	return nil
+}
+
+func (c *MemoryCache) List() ([]Entry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entries := make([]Entry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entry.Content = ""
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return entries, nil
+}
+
+func (c *MemoryCache) Close() error {
+	return nil
+}
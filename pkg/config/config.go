@@ -2,7 +2,11 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/JackBekket/reflexia/pkg/cache"
+	"github.com/JackBekket/reflexia/pkg/provider"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog/log"
 )
@@ -12,7 +16,20 @@ type Config struct {
 	EmbeddingsAIToken             string
 	EmbeddingsDBURL               string
 	CachePath                     string
+	CacheBackend                  string
 	EmbeddingsSimSearchTestPrompt string
+
+	// LLMProvider is one of provider.Providers, OpenAI compatible by default
+	LLMProvider         string
+	LLMProviderSettings provider.Settings
+
+	LLMMaxRetries        int
+	LLMRetryBackoff      time.Duration
+	LLMRetryMaxBackoff   time.Duration
+	LLMRequestsPerMinute int
+	LLMTokensPerMinute   int
+	// LLMPricesPath is an optional toml price table for the usage cost accounting
+	LLMPricesPath string
 }
 
 func NewConfig() Config {
@@ -26,11 +43,62 @@ func NewConfig() Config {
 	if config.CachePath == "" {
 		config.CachePath = ".reflexia_cache"
 	}
+	config.CacheBackend = os.Getenv("CACHE_BACKEND")
+	if config.CacheBackend == "" {
+		config.CacheBackend = cache.BackendFilesystem
+	}
 
 	config.EmbeddingsAIURL = os.Getenv("EMBEDDINGS_AI_URL")
 	config.EmbeddingsAIToken = os.Getenv("EMBEDDINGS_AI_TOKEN")
 	config.EmbeddingsDBURL = os.Getenv("EMBEDDINGS_DB_URL")
 	config.EmbeddingsSimSearchTestPrompt = os.Getenv("EMBEDDINGS_SIM_SEARCH_TEST_PROMPT")
 
+	config.LLMProvider = os.Getenv("LLM_PROVIDER")
+	if config.LLMProvider == "" {
+		config.LLMProvider = provider.OpenAI
+	}
+	config.LLMProviderSettings = provider.Settings{
+		OpenAIAPIVersion:   os.Getenv("OPENAI_API_VERSION"),
+		OpenAIOrganization: os.Getenv("OPENAI_ORGANIZATION"),
+		OllamaKeepAlive:    os.Getenv("OLLAMA_KEEP_ALIVE"),
+		OllamaNumCtx:       getEnvInt("OLLAMA_NUM_CTX", 0),
+		AnthropicMaxTokens: getEnvInt("ANTHROPIC_MAX_TOKENS", 0),
+		AnthropicBeta:      os.Getenv("ANTHROPIC_BETA"),
+		FakeRulesPath:      os.Getenv("FAKE_RULES_PATH"),
+	}
+
+	config.LLMMaxRetries = getEnvInt("LLM_MAX_RETRIES", 3)
+	config.LLMRetryBackoff = getEnvDuration("LLM_RETRY_BACKOFF", 2*time.Second)
+	config.LLMRetryMaxBackoff = getEnvDuration("LLM_RETRY_MAX_BACKOFF", time.Minute)
+	config.LLMRequestsPerMinute = getEnvInt("LLM_REQUESTS_PER_MINUTE", 0)
+	config.LLMTokensPerMinute = getEnvInt("LLM_TOKENS_PER_MINUTE", 0)
+	config.LLMPricesPath = os.Getenv("LLM_PRICES_PATH")
+
 	return config
 }
+
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Warn().Err(err).Msgf("parse %s, using %d", key, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Warn().Err(err).Msgf("parse %s, using %s", key, fallback)
+		return fallback
+	}
+	return d
+}
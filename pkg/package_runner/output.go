@@ -0,0 +1,117 @@
+package packagerunner
+
+import (
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing/fstest"
+	"time"
+)
+
+// Output stores the generated files by the slash separated names relative to its root,
+// such as internal/greeter/README.md
+type Output interface {
+	// ReadFile returns an error matching fs.ErrNotExist for the missing files
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, content []byte) error
+	// Path is the reported location of the file
+	Path(name string) string
+}
+
+// DirOutput writes the files into the directory,
+// the project root one documents the project in place
+type DirOutput string
+
+func (o DirOutput) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(o.Path(name))
+}
+
+func (o DirOutput) WriteFile(name string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(o.Path(name)), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(o.Path(name), content, 0644)
+}
+
+func (o DirOutput) Path(name string) string {
+	return filepath.Join(string(o), filepath.FromSlash(name))
+}
+
+// MemoryOutput keeps the files in memory, it is safe for concurrent use
+type MemoryOutput struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemoryOutput() *MemoryOutput {
+	return &MemoryOutput{files: map[string][]byte{}}
+}
+
+func (o *MemoryOutput) ReadFile(name string) ([]byte, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	content, exists := o.files[path.Clean(name)]
+	if !exists {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return slices.Clone(content), nil
+}
+
+func (o *MemoryOutput) WriteFile(name string, content []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.files[path.Clean(name)] = slices.Clone(content)
+	return nil
+}
+
+func (o *MemoryOutput) Path(name string) string {
+	return path.Clean(name)
+}
+
+// Names returns the sorted names of the written files
+func (o *MemoryOutput) Names() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Sorted(maps.Keys(o.files))
+}
+
+// FS returns the read-only snapshot of the written files
+func (o *MemoryOutput) FS() fs.FS {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	fsys := fstest.MapFS{}
+	now := time.Now()
+	for name, content := range o.files {
+		fsys[name] = &fstest.MapFile{Data: slices.Clone(content), Mode: 0644, ModTime: now}
+	}
+	return fsys
+}
+
+// SubOutput returns the output of the dir subdirectory, e.g. of the project root in the workdir output
+func SubOutput(output Output, dir string) Output {
+	if dir == "" || dir == "." {
+		return output
+	}
+	return subOutput{output: output, dir: filepath.ToSlash(dir)}
+}
+
+type subOutput struct {
+	output Output
+	dir    string
+}
+
+func (o subOutput) ReadFile(name string) ([]byte, error) {
+	return o.output.ReadFile(path.Join(o.dir, name))
+}
+
+func (o subOutput) WriteFile(name string, content []byte) error {
+	return o.output.WriteFile(path.Join(o.dir, name), content)
+}
+
+func (o subOutput) Path(name string) string {
+	return o.output.Path(path.Join(o.dir, name))
+}
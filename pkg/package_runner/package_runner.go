@@ -1,29 +1,180 @@
 package packagerunner
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/JackBekket/reflexia/internal/util"
+	"github.com/JackBekket/reflexia/pkg/cache"
 	"github.com/JackBekket/reflexia/pkg/project"
 	"github.com/JackBekket/reflexia/pkg/store"
 	"github.com/JackBekket/reflexia/pkg/summarize"
+	ignore "github.com/crackcomm/go-gitignore"
 	"github.com/rs/zerolog/log"
 	"github.com/tmc/langchaingo/schema"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 )
 
 type RunStats struct {
-	FallbackFileResponses    []string
-	EmptyFileResponses       []string
-	FallbackPackageResponses []string
-	EmptyPackageResponses    []string
+	FallbackFileResponses    []string `json:"fallback_file_responses,omitempty"`
+	EmptyFileResponses       []string `json:"empty_file_responses,omitempty"`
+	FallbackPackageResponses []string `json:"fallback_package_responses,omitempty"`
+	EmptyPackageResponses    []string `json:"empty_package_responses,omitempty"`
+	// Retries is the total count of the retried LLM requests attempts
+	Retries int `json:"retries"`
+
+	Usage Usage `json:"usage"`
+	// FileUsage is keyed by the file path relative to the project root
+	FileUsage map[string]Usage `json:"file_usage,omitempty"`
+	// PackageUsage includes the package files usage
+	PackageUsage map[string]Usage `json:"package_usage,omitempty"`
+	// PackageSummaries are the summaries of the packages run
+	PackageSummaries map[string]string `json:"package_summaries,omitempty"`
+	// FileSummaries are keyed by the file path relative to the project root like the FileUsage
+	FileSummaries map[string]string `json:"file_summaries,omitempty"`
+	// PackageOutputs are the output paths of the package README and file summary
+	PackageOutputs map[string][]string `json:"package_outputs,omitempty"`
+
+	// Project fields describe the project root README stage,
+	// ProjectReadme is empty if the stage is skipped
+	ProjectReadme   string `json:"project_readme,omitempty"`
+	ProjectSummary  string `json:"project_summary,omitempty"`
+	ProjectFallback bool   `json:"project_fallback,omitempty"`
+	ProjectEmpty    bool   `json:"project_empty,omitempty"`
+	ProjectUsage    Usage  `json:"project_usage"`
+}
+
+func newRunStats() RunStats {
+	return RunStats{
+		FileUsage:        map[string]Usage{},
+		PackageUsage:     map[string]Usage{},
+		PackageSummaries: map[string]string{},
+		FileSummaries:    map[string]string{},
+		PackageOutputs:   map[string][]string{},
+	}
+}
+
+// addPackage adds the package result stats, the fallback and empty file responses are the absolute paths
+func (s *RunStats) addPackage(rootPath, pkg string, result *packageResult) {
+	pkgUsage := result.usage
+	for _, file := range result.files {
+		s.FileUsage[file.relPath] = file.usage
+		s.FileSummaries[file.relPath] = file.summary
+		pkgUsage.Add(file.usage)
+		s.Retries += file.retries
+		absPath := filepath.Join(rootPath, file.relPath)
+		if file.fallback {
+			s.FallbackFileResponses = append(s.FallbackFileResponses, absPath)
+		}
+		if file.empty {
+			s.EmptyFileResponses = append(s.EmptyFileResponses, absPath)
+		}
+	}
+	if result.fallback {
+		s.FallbackPackageResponses = append(s.FallbackPackageResponses, pkg)
+	}
+	if result.empty {
+		s.EmptyPackageResponses = append(s.EmptyPackageResponses, pkg)
+	}
+	s.Retries += result.retries
+	s.PackageUsage[pkg] = pkgUsage
+	s.PackageSummaries[pkg] = result.summary
+	if len(result.outputs) > 0 {
+		s.PackageOutputs[pkg] = result.outputs
+	}
+	s.Usage.Add(pkgUsage)
+}
+
+// Merge adds the other project run stats, prefixing its package keys with pkgPrefix
+// and its file usage keys with the relDir project root
+func (s *RunStats) Merge(other RunStats, pkgPrefix, relDir string) {
+	s.FallbackFileResponses = append(s.FallbackFileResponses, other.FallbackFileResponses...)
+	s.EmptyFileResponses = append(s.EmptyFileResponses, other.EmptyFileResponses...)
+	for _, pkg := range other.FallbackPackageResponses {
+		s.FallbackPackageResponses = append(s.FallbackPackageResponses, pkgPrefix+pkg)
+	}
+	for _, pkg := range other.EmptyPackageResponses {
+		s.EmptyPackageResponses = append(s.EmptyPackageResponses, pkgPrefix+pkg)
+	}
+	s.Retries += other.Retries
+	s.Usage.Add(other.Usage)
+
+	if s.FileUsage == nil {
+		s.FileUsage = map[string]Usage{}
+	}
+	for relPath, usage := range other.FileUsage {
+		s.FileUsage[filepath.Join(relDir, relPath)] = usage
+	}
+	if s.PackageUsage == nil {
+		s.PackageUsage = map[string]Usage{}
+	}
+	for pkg, usage := range other.PackageUsage {
+		s.PackageUsage[pkgPrefix+pkg] = usage
+	}
+	if s.PackageSummaries == nil {
+		s.PackageSummaries = map[string]string{}
+	}
+	for pkg, summary := range other.PackageSummaries {
+		s.PackageSummaries[pkgPrefix+pkg] = summary
+	}
+	if s.FileSummaries == nil {
+		s.FileSummaries = map[string]string{}
+	}
+	for relPath, summary := range other.FileSummaries {
+		s.FileSummaries[filepath.Join(relDir, relPath)] = summary
+	}
+	if s.PackageOutputs == nil {
+		s.PackageOutputs = map[string][]string{}
+	}
+	for pkg, outputs := range other.PackageOutputs {
+		s.PackageOutputs[pkgPrefix+pkg] = outputs
+	}
+
+	// The first project README is kept, the others are reported by the per project stats
+	if s.ProjectReadme == "" {
+		s.ProjectReadme = other.ProjectReadme
+		s.ProjectSummary = other.ProjectSummary
+	}
+	s.ProjectFallback = s.ProjectFallback || other.ProjectFallback
+	s.ProjectEmpty = s.ProjectEmpty || other.ProjectEmpty
+	s.ProjectUsage.Add(other.ProjectUsage)
+}
+
+type Usage struct {
+	Requests         int           `json:"requests"`
+	CacheHits        int           `json:"cache_hits"`
+	CacheMisses      int           `json:"cache_misses"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	EstimatedTokens  bool          `json:"estimated_tokens,omitempty"`
+	Duration         time.Duration `json:"duration"`
+	Cost             float64       `json:"cost,omitempty"`
+}
+
+func (u *Usage) Add(other Usage) {
+	u.Requests += other.Requests
+	u.CacheHits += other.CacheHits
+	u.CacheMisses += other.CacheMisses
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.EstimatedTokens = u.EstimatedTokens || other.EstimatedTokens
+	u.Duration += other.Duration
+	u.Cost += other.Cost
 }
 
 type PackageRunnerService struct {
@@ -32,19 +183,323 @@ type PackageRunnerService struct {
 	SummarizeService  *summarize.SummarizeService
 	EmbeddingsService *store.EmbeddingsService
 	ExactPackages     string
-	OverwriteReadme   bool
-	WithFileSummary   bool
+	// Packages limits the run to the listed packages unless nil,
+	// it is set by the incremental run to the changed packages
+	Packages []string
+	// PackageSummaries of the previous runs are used for the project README
+	// along with the summaries of the packages run
+	PackageSummaries map[string]string
+	OverwriteReadme  bool
+	WithFileSummary  bool
+	// Parallelism is the worker pool size of the packages and files run at once
+	// and the limit of the concurrent LLM requests, values below 1 mean sequential run
+	Parallelism int
+	// Output receives the generated files by the project root relative names,
+	// they are written in place into the project root if it is nil
+	Output Output
+	// PackageDone is called with the stats of the single package in the sorted package order
+	// as soon as the package and the preceding ones are done, before the project README stage
+	PackageDone func(pkg string, stats RunStats)
+	// ReadmeNames are shared by the services run into the same output, e.g. the projects of a workdir,
+	// so the packages of the same directory never get the same readme, every run reserves its own if nil
+	ReadmeNames *ReadmeNames
+
+	// readmes are the readme names reserved by the packages of the run, keyed by the package,
+	// the empty key is the project README, see reserveReadme
+	readmeMu    sync.Mutex
+	readmes     map[string]string
+	readmeNames *ReadmeNames
 
 	Model   string
 	PrintTo io.Writer
 }
 
+const defaultChunkReducePrompt = `The following are summaries of consecutive parts of one source file.
+Merge them into a single summary of the whole file, keeping the structure of the part summaries,
+removing the duplicates and not mentioning that the file was split into parts.
+`
+
+type packagePrompts struct {
+	name                  string
+	codePrompt            prompt
+	codePromptFallback    prompt
+	packagePrompt         prompt
+	packagePromptFallback prompt
+	chunkReducePrompt     prompt
+	projectPrompt         prompt
+	projectPromptFallback prompt
+	// pathPrompts override the prompts of the matching files and package directories, later ones win
+	pathPrompts []pathPrompts
+}
+
+type pathPrompts struct {
+	matcher *ignore.GitIgnore
+	prompts packagePrompts
+}
+
+func (p *packagePrompts) fields() map[string]*prompt {
+	return map[string]*prompt{
+		"code":             &p.codePrompt,
+		"code_fallback":    &p.codePromptFallback,
+		"package":          &p.packagePrompt,
+		"package_fallback": &p.packagePromptFallback,
+		"chunk_reduce":     &p.chunkReducePrompt,
+		"project":          &p.projectPrompt,
+		"project_fallback": &p.projectPromptFallback,
+	}
+}
+
+// forPath returns the prompts with the non-empty prompts of the path prompts matching the
+// project root relative path, the package directories are matched with the trailing slash
+func (p packagePrompts) forPath(relPath string) packagePrompts {
+	result := p
+	for _, override := range p.pathPrompts {
+		if !override.matcher.MatchesPath(relPath) {
+			continue
+		}
+		overrideFields := override.prompts.fields()
+		for key, field := range result.fields() {
+			if !overrideFields[key].empty() {
+				*field = *overrideFields[key]
+			}
+		}
+	}
+	return result
+}
+
+// newPackagePrompts parses the prompt texts by the prompts toml key, the missing ones are empty
+func newPackagePrompts(name string, texts map[string]string) (packagePrompts, error) {
+	prompts := packagePrompts{name: name}
+	for key, field := range prompts.fields() {
+		var err error
+		if *field, err = newPrompt(name+"."+key, texts[key]); err != nil {
+			return packagePrompts{}, err
+		}
+	}
+	return prompts, nil
+}
+
+// promptTexts maps the prompts toml keys to the set prompts
+func promptTexts(pcPrompts project.ProjectConfigPrompts) map[string]string {
+	texts := map[string]string{
+		"code":         pcPrompts.CodePrompt,
+		"package":      pcPrompts.PackagePrompt,
+		"chunk_reduce": pcPrompts.ChunkReducePrompt,
+		"project":      pcPrompts.ProjectPrompt,
+	}
+	for key, text := range map[string]*string{
+		"code_fallback":    pcPrompts.CodePromptFallback,
+		"package_fallback": pcPrompts.PackagePromptFallback,
+		"project_fallback": pcPrompts.ProjectPromptFallback,
+	} {
+		if text != nil {
+			texts[key] = *text
+		}
+	}
+	return texts
+}
+
+type fileResult struct {
+	relPath  string
+	summary  string
+	imports  []string
+	fallback bool
+	empty    bool
+	retries  int
+	usage    Usage
+}
+
+type packageResult struct {
+	output  bytes.Buffer
+	summary string
+	// outputs are the written files paths
+	outputs  []string
+	files    []fileResult
+	fallback bool
+	empty    bool
+	retries  int
+	usage    Usage
+}
+
+// requestScope limits the LLM requests concurrency with the semaphore
+// shared by the whole run and accumulates the stats of its requests.
+// The files semaphore limits the files read and summarized at once,
+// limit is the worker pool size of the packages, files and chunks
+type requestScope struct {
+	sem   *semaphore.Weighted
+	files *semaphore.Weighted
+	limit int
+
+	mu      sync.Mutex
+	retries int
+	usage   Usage
+}
+
+func (r *requestScope) child() *requestScope {
+	return &requestScope{sem: r.sem, files: r.files, limit: r.limit}
+}
+
+func (r *requestScope) add(info summarize.RequestInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries += info.Retries
+	usage := Usage{Requests: 1}
+	if info.CacheHit {
+		usage.CacheHits = 1
+	} else {
+		usage.CacheMisses = 1
+		usage.PromptTokens = info.PromptTokens
+		usage.CompletionTokens = info.CompletionTokens
+		usage.EstimatedTokens = info.EstimatedTokens
+		usage.Duration = info.Duration
+		usage.Cost = info.Cost
+	}
+	r.usage.Add(usage)
+}
+
 func (s *PackageRunnerService) RunPackages(ctx context.Context) (RunStats, error) {
 	stats := RunStats{}
 
-	pcPrompts, ok := s.ProjectConfig.Prompts["default"]
+	prompts, err := s.choosePrompts()
+	if err != nil {
+		return stats, err
+	}
+
+	pkgs := []string{}
+	// unchanged are the packages skipped by the incremental run
+	unchanged := []string{}
+	for pkg := range s.PkgFiles {
+		if s.ExactPackages != "" &&
+			!slices.Contains(strings.Split(s.ExactPackages, ","), pkg) {
+			continue
+		}
+		if s.Packages != nil && !slices.Contains(s.Packages, pkg) {
+			unchanged = append(unchanged, pkg)
+			continue
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	slices.Sort(pkgs)
+	slices.Sort(unchanged)
+
+	// The project README is written along with the packages
+	projectReadme := !prompts.projectPrompt.empty() && len(pkgs) > 0
+
+	// Readme names are reserved in the sorted package order before the parallel run,
+	// so the packages of the same directory always get the same distinct readmes
+	s.readmes, s.readmeNames = map[string]string{}, s.ReadmeNames
+	if s.readmeNames == nil {
+		s.readmeNames = NewReadmeNames()
+	}
+	for _, pkg := range pkgs {
+		relDir := filepath.ToSlash(s.ProjectConfig.PackageDir(s.PkgFiles[pkg]))
+		if !writesPackageReadme(prompts, relDir) {
+			continue
+		}
+		if _, err := s.reserveReadme(pkg, relDir); err != nil {
+			return stats, err
+		}
+	}
+	if projectReadme {
+		if _, err := s.reserveReadme("", "."); err != nil {
+			return stats, err
+		}
+	}
+	links := map[string]string{}
+	if graph, err := s.ProjectConfig.BuildPackageGraph(s.PkgFiles); err != nil {
+		log.Warn().Err(err).Msg("build package graph, skipping the package links")
+	} else {
+		if links, err = s.packageLinks(graph, pkgs, projectReadme); err != nil {
+			return stats, err
+		}
+		if err := s.refreshLinks(unchanged, pkgs, links, !prompts.projectPrompt.empty()); err != nil {
+			return stats, err
+		}
+	}
+
+	parallelism := max(s.Parallelism, 1)
+	scope := &requestScope{
+		sem:   semaphore.NewWeighted(int64(parallelism)),
+		files: semaphore.NewWeighted(int64(parallelism)),
+		limit: parallelism,
+	}
+
+	results := make([]*packageResult, len(pkgs))
+	done := make([]chan struct{}, len(pkgs))
+	for i := range pkgs {
+		results[i] = &packageResult{}
+		done[i] = make(chan struct{})
+	}
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(parallelism)
+	for i, pkg := range pkgs {
+		group.Go(func() error {
+			defer close(done[i])
+			return s.runPackage(groupCtx, scope, prompts, pkg, links[pkg], results[i])
+		})
+	}
+
+	// Package outputs are printed in the sorted package order
+	// as soon as all of the preceding packages are done
+	stats = newRunStats()
+	for i, pkg := range pkgs {
+		select {
+		case <-done[i]:
+		case <-groupCtx.Done():
+		}
+		if groupCtx.Err() != nil {
+			break
+		}
+		if _, err := results[i].output.WriteTo(s.PrintTo); err != nil {
+			log.Warn().Err(err).Msg("print package output")
+		}
+		stats.addPackage(s.ProjectConfig.RootPath, pkg, results[i])
+		if s.PackageDone != nil {
+			pkgStats := newRunStats()
+			pkgStats.addPackage(s.ProjectConfig.RootPath, pkg, results[i])
+			s.PackageDone(pkg, pkgStats)
+		}
+	}
+	if err := group.Wait(); err != nil {
+		return stats, err
+	}
+
+	if prompts.projectPrompt.empty() || len(pkgs) == 0 {
+		return stats, nil
+	}
+	pkgSummaries := map[string]string{}
+	for pkg := range s.PkgFiles {
+		summary, ok := stats.PackageSummaries[pkg]
+		if !ok {
+			summary = s.PackageSummaries[pkg]
+		}
+		if strings.TrimSpace(summary) != "" {
+			pkgSummaries[pkg] = summary
+		}
+	}
+	result := &packageResult{}
+	stats.ProjectReadme, err = s.runProject(ctx, scope, prompts, pkgSummaries, result)
+	if _, err := result.output.WriteTo(s.PrintTo); err != nil {
+		log.Warn().Err(err).Msg("print project output")
+	}
+	if err != nil {
+		return stats, err
+	}
+	stats.ProjectSummary = result.summary
+	stats.ProjectFallback = result.fallback
+	stats.ProjectEmpty = result.empty
+	stats.ProjectUsage = result.usage
+	stats.Retries += result.retries
+	stats.Usage.Add(result.usage)
+
+	return stats, nil
+}
+
+func (s *PackageRunnerService) choosePrompts() (packagePrompts, error) {
+	name := "default"
+	pcPrompts, ok := s.ProjectConfig.Prompts[name]
 	if !ok {
-		return stats, fmt.Errorf("failed to load default prompts")
+		return packagePrompts{}, fmt.Errorf("failed to load default prompts")
 	}
 
 	for model, prompts := range s.ProjectConfig.Prompts {
@@ -53,217 +508,683 @@ func (s *PackageRunnerService) RunPackages(ctx context.Context) (RunStats, error
 		}
 
 		matched, err := regexp.MatchString(model, s.Model)
-		if err == nil && matched {
+		if err != nil {
+			return packagePrompts{}, fmt.Errorf("match %s model prompts: %w", model, err)
+		}
+		if matched {
+			name = model
 			pcPrompts = prompts
 			break
 		}
 	}
 
-	codePrompt := pcPrompts.CodePrompt
-	codePromptFallback := ""
-	if pcPrompts.CodePromptFallback != nil {
-		codePromptFallback = *pcPrompts.CodePromptFallback
-	}
-	packagePrompt := pcPrompts.PackagePrompt
-	packagePromptFallback := ""
-	if pcPrompts.PackagePromptFallback != nil {
-		packagePromptFallback = *pcPrompts.PackagePromptFallback
+	texts := promptTexts(pcPrompts)
+	texts["chunk_reduce"] = cmp.Or(texts["chunk_reduce"], defaultChunkReducePrompt)
+	// Model specific prompts without the project prompt use the default ones
+	if pcPrompts.ProjectPrompt == "" {
+		defaultTexts := promptTexts(s.ProjectConfig.Prompts["default"])
+		texts["project"] = defaultTexts["project"]
+		texts["project_fallback"] = defaultTexts["project_fallback"]
 	}
 
-	for pkg, files := range s.PkgFiles {
-		if s.ExactPackages != "" &&
-			!slices.Contains(strings.Split(s.ExactPackages, ","), pkg) {
-			continue
+	prompts, err := newPackagePrompts(name, texts)
+	if err != nil {
+		return packagePrompts{}, err
+	}
+	for i, pathPrompt := range s.ProjectConfig.PathPrompts {
+		matcher, err := ignore.CompileIgnoreLines(pathPrompt.Paths...)
+		if err != nil {
+			return packagePrompts{}, fmt.Errorf("compile path_prompts %d paths: %w", i, err)
+		}
+		overrides, err := newPackagePrompts(fmt.Sprintf("path_prompts.%d", i), promptTexts(pathPrompt.Prompts))
+		if err != nil {
+			return packagePrompts{}, err
 		}
-		fmt.Fprintf(s.PrintTo, "Package %s\n", pkg)
+		prompts.pathPrompts = append(prompts.pathPrompts, pathPrompts{matcher: matcher, prompts: overrides})
+	}
+	return prompts, nil
+}
 
-		pkgFileMap := map[string]string{}
-		pkgDir := ""
-		for _, relPath := range files {
-			fmt.Fprintf(s.PrintTo, "%s\n", relPath)
-			content, err := os.ReadFile(filepath.Join(s.ProjectConfig.RootPath, relPath))
+// promptData returns the prompt template variables common to all prompts
+func (s *PackageRunnerService) promptData(pkg string) PromptData {
+	return PromptData{
+		Language: strings.TrimSuffix(s.ProjectConfig.Name, filepath.Ext(s.ProjectConfig.Name)),
+		Package:  pkg,
+	}
+}
+
+func (s *PackageRunnerService) runPackage(
+	ctx context.Context,
+	scope *requestScope,
+	prompts packagePrompts,
+	pkg, links string,
+	result *packageResult,
+) error {
+	fmt.Fprintf(&result.output, "Package %s\n", pkg)
+	ctx = s.withMetadata(ctx, prompts, pkg, "")
+	pkgScope := scope.child()
+	defer func() {
+		result.retries = pkgScope.retries
+		result.usage = pkgScope.usage
+	}()
+
+	fileOutputs := make([]bytes.Buffer, len(s.PkgFiles[pkg]))
+	result.files = make([]fileResult, len(fileOutputs))
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(scope.limit)
+	for i, relPath := range s.PkgFiles[pkg] {
+		group.Go(func() error {
+			file, err := s.runFile(groupCtx, scope.child(), prompts, pkg, relPath, &fileOutputs[i])
 			if err != nil {
-				return stats, err
+				return err
 			}
+			result.files[i] = file
+			return nil
+		})
+	}
+	err := group.Wait()
+	for i := range fileOutputs {
+		if _, err := fileOutputs[i].WriteTo(&result.output); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
 
-			contentStr := string(content)
-			codeSummaryContent := "Empty file"
-
-			if strings.TrimSpace(contentStr) != "" {
-				codeSummaryContent, err = s.SummarizeService.LLMRequest(ctx,
-					"%s```\n%s\n```",
-					codePrompt, contentStr,
-				)
-				if err != nil {
-					return stats, err
-				}
-				if strings.TrimSpace(codeSummaryContent) == "" &&
-					codePromptFallback != "" {
-					stats.FallbackFileResponses = append(
-						stats.FallbackFileResponses,
-						filepath.Join(s.ProjectConfig.RootPath, relPath),
-					)
-					codeSummaryContent, err = s.SummarizeService.LLMRequest(ctx,
-						"%s```\n%s\n```",
-						codePromptFallback, contentStr,
-					)
-					if err != nil {
-						return stats, err
-					}
-				}
-			}
-			if strings.TrimSpace(codeSummaryContent) == "" {
-				stats.EmptyFileResponses = append(
-					stats.EmptyFileResponses,
-					filepath.Join(s.ProjectConfig.RootPath, relPath),
-				)
-				fmt.Fprintf(s.PrintTo, "[WARN] empty file summary!\n")
-			} else {
-				fmt.Fprintf(s.PrintTo, "%s\n", codeSummaryContent)
-			}
-			fmt.Fprintf(s.PrintTo, "\n")
-
-			pkgFileMap[relPath] = codeSummaryContent
-			if s.EmbeddingsService != nil {
-				ids, err := s.EmbeddingsService.Store.AddDocuments(ctx,
-					[]schema.Document{
-						{
-							PageContent: string(content),
-							Metadata: map[string]interface{}{
-								"package":  pkg,
-								"filename": relPath,
-								"type":     "code",
-							},
-						}, {
-							PageContent: codeSummaryContent,
-							Metadata: map[string]interface{}{
-								"package":  pkg,
-								"filename": relPath,
-								"type":     "doc",
-							},
-						},
-					},
-				)
-				if err != nil {
-					return stats, err
-				}
-				fmt.Fprintf(s.PrintTo, "Succesfully pushed docs %s into embeddings vector store\n", ids)
-			}
+	pkgFileMap := map[string]string{}
+	pkgImports := []string{}
+	for _, file := range result.files {
+		pkgFileMap[file.relPath] = file.summary
+		pkgImports = append(pkgImports, file.imports...)
+	}
+	slices.Sort(pkgImports)
+	pkgDir, relDir := "", ""
+	if len(result.files) > 0 {
+		relDir = filepath.ToSlash(s.ProjectConfig.PackageDir(s.PkgFiles[pkg]))
+		pkgDir = filepath.Join(s.ProjectConfig.RootPath, relDir)
+		prompts = prompts.forPath(relDir + "/")
+	}
+	if pkgDir == "" {
+		log.Info().Msgf("There is no mathcing files for pkg: %s", pkg)
+		return nil
+	}
 
-			if pkgDir == "" {
-				pkgDir = filepath.Join(s.ProjectConfig.RootPath, filepath.Dir(relPath))
-			}
+	fileStructure, err := getDirFileStructure(pkg, pkgDir)
+	if err != nil {
+		log.Warn().Err(err).Msg("getDirFileStructure error")
+	}
+
+	fmt.Fprintf(&result.output, "Summary for a package %s: \n", pkg)
+	// Generate Summary for a package (summarizing and group file summarization by package name)
+	// Get whole map of code summaries as a string and toss it to summarize .MD for a package
+	data := s.promptData(pkg)
+	data.Imports = slices.Compact(pkgImports)
+	data.FileSummaries = pkgFileMap
+	data.Structure = fileStructure
+	pkgSummaryContent, err := s.promptRequest(ctx, pkgScope,
+		prompts.packagePrompt, data, "%s\n\n%s\n%s", fileStructure, fileMapToString(pkgFileMap),
+	)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(pkgSummaryContent) == "" && !prompts.packagePromptFallback.empty() {
+		result.fallback = true
+		pkgSummaryContent, err = s.promptRequest(ctx, pkgScope,
+			prompts.packagePromptFallback, data, "%s\n\n%s\n%s", fileStructure, fileMapToString(pkgFileMap),
+		)
+		if err != nil {
+			return err
 		}
-		if pkgDir == "" {
-			log.Info().Msgf("There is no mathcing files for pkg: %s", pkg)
-			continue
+	}
+
+	if strings.TrimSpace(pkgSummaryContent) == "" {
+		result.empty = true
+		fmt.Fprintf(&result.output, "[WARN] empty package summary\n")
+	} else {
+		fmt.Fprintf(&result.output, "%s\n", pkgSummaryContent)
+	}
+	fmt.Fprintf(&result.output, "\n")
+	result.summary = pkgSummaryContent
+
+	if s.EmbeddingsService != nil {
+		ids, err := s.EmbeddingsService.Store.AddDocuments(ctx,
+			[]schema.Document{
+				{
+					PageContent: pkgSummaryContent,
+					Metadata: map[string]interface{}{
+						"package": pkg,
+						"type":    "doc",
+					},
+				},
+			},
+		)
+		if err != nil {
+			return err
 		}
+		fmt.Fprintf(&result.output, "Succesfully pushed docs %s into embeddings vector store\n", ids)
+	}
 
-		fileStructure, err := getDirFileStructure(pkg, pkgDir)
+	if writesPackageReadme(prompts, relDir) {
+		readmePath, err := s.writeReadme(pkg, relDir, pkgSummaryContent+links)
 		if err != nil {
-			log.Warn().Err(err).Msg("getDirFileStructure error")
+			return err
+		}
+		result.outputs = append(result.outputs, readmePath)
+	}
+
+	if s.WithFileSummary {
+		name := path.Join(relDir, s.ProjectConfig.Output.FileSummaryFilename())
+		if err := s.output().WriteFile(name, []byte(fileMapToMd(pkgFileMap))); err != nil {
+			return err
 		}
+		result.outputs = append(result.outputs, s.output().Path(name))
+	}
+	return nil
+}
+
+func (s *PackageRunnerService) runFile(
+	ctx context.Context,
+	scope *requestScope,
+	prompts packagePrompts,
+	pkg, relPath string,
+	output io.Writer,
+) (fileResult, error) {
+	result := fileResult{relPath: relPath}
+	prompts = prompts.forPath(relPath)
+	ctx = s.withMetadata(ctx, prompts, pkg, relPath)
+
+	fmt.Fprintf(output, "%s\n", relPath)
+	// The file content is held only by the file slot holders
+	if err := scope.files.Acquire(ctx, 1); err != nil {
+		return result, err
+	}
+	defer scope.files.Release(1)
+	content, err := os.ReadFile(filepath.Join(s.ProjectConfig.RootPath, relPath))
+	if err != nil {
+		return result, err
+	}
+
+	contentStr := string(content)
+	codeSummaryContent := "Empty file"
+	result.imports = project.FileImports(relPath, contentStr)
 
-		fmt.Fprintf(s.PrintTo, "Summary for a package %s: \n", pkg)
-		// Generate Summary for a package (summarizing and group file summarization by package name)
-		// Get whole map of code summaries as a string and toss it to summarize .MD for a package
-		pkgSummaryContent, err := s.SummarizeService.LLMRequest(ctx,
-			"%s\n\n%s\n%s",
-			packagePrompt,
-			fileStructure,
-			fileMapToString(pkgFileMap),
+	if strings.TrimSpace(contentStr) != "" {
+		data := s.promptData(pkg)
+		data.FilePath = relPath
+		data.Content = contentStr
+		data.Imports = result.imports
+		codeSummaryContent, err = s.summarizeContent(ctx, scope,
+			prompts.codePrompt, prompts.chunkReducePrompt, data,
 		)
 		if err != nil {
-			return stats, err
+			return result, err
 		}
-
-		if strings.TrimSpace(pkgSummaryContent) == "" && packagePromptFallback != "" {
-			stats.FallbackPackageResponses = append(stats.FallbackPackageResponses, pkg)
-			pkgSummaryContent, err = s.SummarizeService.LLMRequest(ctx,
-				"%s\n\n%s\n%s",
-				packagePromptFallback,
-				fileStructure,
-				fileMapToString(pkgFileMap),
+		if strings.TrimSpace(codeSummaryContent) == "" &&
+			!prompts.codePromptFallback.empty() {
+			result.fallback = true
+			codeSummaryContent, err = s.summarizeContent(ctx, scope,
+				prompts.codePromptFallback, prompts.chunkReducePrompt, data,
 			)
 			if err != nil {
-				return stats, err
+				return result, err
 			}
 		}
+	}
+	if strings.TrimSpace(codeSummaryContent) == "" {
+		result.empty = true
+		fmt.Fprintf(output, "[WARN] empty file summary!\n")
+	} else {
+		fmt.Fprintf(output, "%s\n", codeSummaryContent)
+	}
+	fmt.Fprintf(output, "\n")
+	result.summary = codeSummaryContent
 
-		if strings.TrimSpace(pkgSummaryContent) == "" {
-			stats.EmptyPackageResponses = append(stats.EmptyPackageResponses, pkg)
-			fmt.Fprintf(s.PrintTo, "[WARN] empty package summary\n")
-		} else {
-			fmt.Fprintf(s.PrintTo, "%s\n", pkgSummaryContent)
-		}
-		fmt.Fprintf(s.PrintTo, "\n")
-
-		if s.EmbeddingsService != nil {
-			ids, err := s.EmbeddingsService.Store.AddDocuments(ctx,
-				[]schema.Document{
-					{
-						PageContent: pkgSummaryContent,
-						Metadata: map[string]interface{}{
-							"package": pkg,
-							"type":    "doc",
-						},
+	if s.EmbeddingsService != nil {
+		ids, err := s.EmbeddingsService.Store.AddDocuments(ctx,
+			[]schema.Document{
+				{
+					PageContent: contentStr,
+					Metadata: map[string]interface{}{
+						"package":  pkg,
+						"filename": relPath,
+						"type":     "code",
+					},
+				}, {
+					PageContent: codeSummaryContent,
+					Metadata: map[string]interface{}{
+						"package":  pkg,
+						"filename": relPath,
+						"type":     "doc",
 					},
 				},
+			},
+		)
+		if err != nil {
+			return result, err
+		}
+		fmt.Fprintf(output, "Succesfully pushed docs %s into embeddings vector store\n", ids)
+	}
+
+	result.retries = scope.retries
+	result.usage = scope.usage
+	return result, nil
+}
+
+// summarizeContent requests the file summary with a single prompt,
+// or splits the oversized file into chunks, summarizes each of them
+// and reduces the chunk summaries into one
+func (s *PackageRunnerService) summarizeContent(
+	ctx context.Context,
+	scope *requestScope,
+	codePrompt, reducePrompt prompt,
+	data PromptData,
+) (string, error) {
+	relPath, content := data.FilePath, data.Content
+	chunking := s.ProjectConfig.Chunking
+	if chunking.MaxTokens <= 0 || summarize.EstimateTokens(content) <= chunking.MaxTokens {
+		return s.promptRequest(ctx, scope, codePrompt, data, "%s```\n%s\n```", content)
+	}
+	chunkTokens := chunking.ChunkTokens
+	if chunkTokens <= 0 || chunkTokens > chunking.MaxTokens {
+		chunkTokens = chunking.MaxTokens
+	}
+
+	var chunks []string
+	if s.ProjectConfig.ModuleMatch == "go_package" {
+		var err error
+		chunks, err = summarize.SplitGoDecls(content, chunkTokens)
+		if err != nil {
+			log.Warn().Err(err).Msgf("split %s by declarations, falling back to lines", relPath)
+		}
+	}
+	if len(chunks) == 0 {
+		chunks = summarize.SplitLines(content, chunkTokens)
+	}
+
+	summaries := make([]string, len(chunks))
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(scope.limit)
+	for i, chunk := range chunks {
+		group.Go(func() error {
+			chunkData := data
+			chunkData.Content = chunk
+			chunkData.Part, chunkData.Parts = i+1, len(chunks)
+			summary, err := s.promptRequest(groupCtx, scope, codePrompt, chunkData,
+				"%s\nPart %d of %d of the %s file:\n```\n%s\n```",
+				i+1, len(chunks), relPath, chunk,
 			)
-			if err != nil {
-				return stats, err
+			summaries[i] = summary
+			return err
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return "", err
+	}
+
+	data.Content = ""
+	return s.reduceSummaries(ctx, scope, reducePrompt, data, chunkTokens, summaries)
+}
+
+// reduceSummaries merges the chunk summaries in groups fitting into chunkTokens,
+// repeating until only one summary is left
+func (s *PackageRunnerService) reduceSummaries(
+	ctx context.Context,
+	scope *requestScope,
+	reducePrompt prompt,
+	data PromptData,
+	chunkTokens int,
+	summaries []string,
+) (string, error) {
+	summaries = slices.DeleteFunc(summaries, func(summary string) bool {
+		return strings.TrimSpace(summary) == ""
+	})
+	switch len(summaries) {
+	case 0:
+		return "", nil
+	case 1:
+		return summaries[0], nil
+	}
+
+	groups := [][]string{}
+	groupTokens := 0
+	for _, summary := range summaries {
+		tokens := summarize.EstimateTokens(summary)
+		// Every group except the last one holds at least two summaries,
+		// so each iteration shrinks the summaries count
+		if len(groups) == 0 || (groupTokens+tokens > chunkTokens && len(groups[len(groups)-1]) > 1) {
+			groups = append(groups, []string{})
+			groupTokens = 0
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], summary)
+		groupTokens += tokens
+	}
+
+	reduced := make([]string, len(groups))
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, parts := range groups {
+		if len(parts) == 1 {
+			reduced[i] = parts[0]
+			continue
+		}
+		group.Go(func() error {
+			partsData := data
+			partsData.FileSummaries = map[string]string{}
+			for j, part := range parts {
+				partsData.FileSummaries[fmt.Sprintf("%s part %d", data.FilePath, j+1)] = part
 			}
-			fmt.Fprintf(s.PrintTo, "Succesfully pushed docs %s into embeddings vector store\n", ids)
+			summary, err := s.promptRequest(groupCtx, scope, reducePrompt, partsData,
+				"%s\nSummaries of the %s file parts:\n\n%s",
+				data.FilePath, strings.Join(parts, "\n\n"),
+			)
+			reduced[i] = summary
+			return err
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return "", err
+	}
+
+	return s.reduceSummaries(ctx, scope, reducePrompt, data, chunkTokens, reduced)
+}
+
+func (s *PackageRunnerService) withMetadata(
+	ctx context.Context, prompts packagePrompts, pkg, relPath string,
+) context.Context {
+	return summarize.WithMetadata(ctx, cache.Metadata{
+		ProjectConfig: s.ProjectConfig.Name,
+		Prompts:       prompts.name,
+		Package:       pkg,
+		SourcePath:    relPath,
+	})
+}
+
+// llmRequest holds one of the shared parallelism slots for the request duration
+func (s *PackageRunnerService) llmRequest(
+	ctx context.Context, scope *requestScope, format string, a ...any,
+) (string, error) {
+	if err := scope.sem.Acquire(ctx, 1); err != nil {
+		return "", err
+	}
+	defer scope.sem.Release(1)
+	response, info, err := s.SummarizeService.LLMRequestWithInfo(ctx, format, a...)
+	scope.add(info)
+	return response, err
+}
+
+// promptRequest renders the prompt with the data, the plain prompt is formatted
+// as the first argument of the format followed by the args
+func (s *PackageRunnerService) promptRequest(
+	ctx context.Context, scope *requestScope, p prompt, data PromptData, format string, a ...any,
+) (string, error) {
+	request, err := p.render(data, format, a...)
+	if err != nil {
+		return "", err
+	}
+	return s.llmRequest(ctx, scope, "%s", request)
+}
+
+// runProject summarizes the package summaries and the project package structure
+// into the project root README
+func (s *PackageRunnerService) runProject(
+	ctx context.Context,
+	scope *requestScope,
+	prompts packagePrompts,
+	pkgSummaries map[string]string,
+	result *packageResult,
+) (string, error) {
+	ctx = s.withMetadata(ctx, prompts, "", "")
+	projectScope := scope.child()
+	defer func() {
+		result.retries = projectScope.retries
+		result.usage = projectScope.usage
+	}()
+
+	structure := s.packageStructure()
+	fmt.Fprintf(&result.output, "Summary for the project: \n")
+	data := s.promptData("")
+	data.PackageSummaries = pkgSummaries
+	data.Structure = structure
+	projectSummaryContent, err := s.promptRequest(ctx, projectScope,
+		prompts.projectPrompt, data, "%s\n\n%s\n%s", structure, fileMapToString(pkgSummaries),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(projectSummaryContent) == "" && !prompts.projectPromptFallback.empty() {
+		result.fallback = true
+		projectSummaryContent, err = s.promptRequest(ctx, projectScope,
+			prompts.projectPromptFallback, data, "%s\n\n%s\n%s", structure, fileMapToString(pkgSummaries),
+		)
+		if err != nil {
+			return "", err
 		}
+	}
 
-		readmeFilename := "README.md"
-		if !s.OverwriteReadme {
-			readmeFilename, err = getReadmePath(pkgDir)
-			if err != nil {
-				return stats, err
+	if strings.TrimSpace(projectSummaryContent) == "" {
+		result.empty = true
+		fmt.Fprintf(&result.output, "[WARN] empty project summary\n")
+	} else {
+		fmt.Fprintf(&result.output, "%s\n", projectSummaryContent)
+	}
+	fmt.Fprintf(&result.output, "\n")
+	result.summary = projectSummaryContent
+
+	return s.writeReadme("", ".", projectSummaryContent)
+}
+
+// writesPackageReadme reports whether the package of the project root relative dir has its own readme,
+// the project root package summary is a part of the project README
+func writesPackageReadme(prompts packagePrompts, relDir string) bool {
+	return relDir != "." || prompts.forPath(relDir+"/").projectPrompt.empty()
+}
+
+// writeReadme writes the readme reserved by the package, or by the project for the empty key.
+// The output path of the written file is returned
+func (s *PackageRunnerService) writeReadme(key, relDir, content string) (string, error) {
+	name, err := s.reserveReadme(key, relDir)
+	if err != nil {
+		return "", err
+	}
+	output := s.output()
+	return output.Path(name), output.WriteFile(name, []byte(content))
+}
+
+// reserveReadme returns the readme name of the package key reserving it on the first call.
+// The readme is written if it is overwritten or absent, the generated readme otherwise,
+// README.md and README_GENERATED.md by default. The names reserved in the output by the other packages
+// of the same directory are skipped, numbering the generated readme, e.g. README_GENERATED_2.md
+func (s *PackageRunnerService) reserveReadme(key, relDir string) (string, error) {
+	s.readmeMu.Lock()
+	defer s.readmeMu.Unlock()
+	if s.readmes == nil {
+		s.readmes = map[string]string{}
+	}
+	if s.readmeNames == nil {
+		s.readmeNames = s.ReadmeNames
+		if s.readmeNames == nil {
+			s.readmeNames = NewReadmeNames()
+		}
+	}
+	if name, ok := s.readmes[key]; ok {
+		return name, nil
+	}
+
+	readme, err := s.readmeName(relDir)
+	if err != nil {
+		return "", err
+	}
+	generated := s.ProjectConfig.Output.GeneratedReadmeFilename()
+	ext := path.Ext(generated)
+	name := s.readmeNames.reserve(s.output(), func(i int) string {
+		switch i {
+		case 0:
+			return readme
+		case 1:
+			return path.Join(relDir, generated)
+		}
+		return path.Join(relDir, fmt.Sprintf("%s_%d%s", strings.TrimSuffix(generated, ext), i, ext))
+	})
+	s.readmes[key] = name
+	return name, nil
+}
+
+// ReadmeNames are the output paths of the readmes reserved by the runs, it is safe for concurrent use
+type ReadmeNames struct {
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+func NewReadmeNames() *ReadmeNames {
+	return &ReadmeNames{paths: map[string]bool{}}
+}
+
+// reserve reserves and returns the first of the names by the index whose output path is not reserved
+func (r *ReadmeNames) reserve(output Output, name func(i int) string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; ; i++ {
+		if n := name(i); !r.paths[output.Path(n)] {
+			r.paths[output.Path(n)] = true
+			return n
+		}
+	}
+}
+
+// readmeName returns the output name of the readme written into the project root relative dir.
+// The existing readme is looked up in the source directory, so the readmes written by the previous runs
+// into a separate output are overwritten
+func (s *PackageRunnerService) readmeName(relDir string) (string, error) {
+	name := path.Join(relDir, s.ProjectConfig.Output.ReadmeFilename())
+	if !s.OverwriteReadme {
+		if _, err := os.Stat(filepath.Join(s.ProjectConfig.RootPath, filepath.FromSlash(name))); err == nil {
+			name = path.Join(relDir, s.ProjectConfig.Output.GeneratedReadmeFilename())
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// packageLinks returns the "Depends on" and "Used by" readme sections of the packages
+// with the relative links to the readmes of the other package directories.
+// Only the readmes of the packages run, the project README if it is written
+// and the readmes existing in the output are linked.
+// The readme names are resolved before any of them is written by the run
+func (s *PackageRunnerService) packageLinks(
+	graph project.PackageGraph, pkgs []string, projectReadme bool,
+) (map[string]string, error) {
+	readmes := map[string]string{}
+	linked := map[string]bool{}
+	for pkg, files := range s.PkgFiles {
+		relDir := filepath.ToSlash(s.ProjectConfig.PackageDir(files))
+		name, reserved := s.readmes[pkg]
+		if relDir == "." && !reserved {
+			// The root package summary is a part of the project README
+			name, reserved = s.readmes[""]
+		}
+		if !reserved {
+			var err error
+			if name, err = s.readmeName(relDir); err != nil {
+				return nil, err
 			}
 		}
-		if err := writeFile(
-			filepath.Join(pkgDir, readmeFilename),
-			pkgSummaryContent,
-		); err != nil {
-			return stats, err
+		readmes[pkg] = name
+		linked[pkg] = slices.Contains(pkgs, pkg) || relDir == "." && projectReadme
+		if !linked[pkg] {
+			if _, err := s.output().ReadFile(name); err == nil {
+				linked[pkg] = true
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
 		}
+	}
 
-		if s.WithFileSummary {
-			if err := writeFile(
-				filepath.Join(pkgDir, "FILES.md"),
-				fileMapToMd(pkgFileMap),
-			); err != nil {
-				return stats, err
+	links := map[string]string{}
+	section := func(pkg, title string, related []string) string {
+		content := ""
+		for _, other := range related {
+			if !linked[other] || path.Dir(readmes[other]) == path.Dir(readmes[pkg]) {
+				continue
+			}
+			link, err := filepath.Rel(path.Dir(readmes[pkg]), readmes[other])
+			if err != nil {
+				continue
 			}
+			content += fmt.Sprintf("- [%s](%s)\n", other, filepath.ToSlash(link))
 		}
+		if content == "" {
+			return ""
+		}
+		return fmt.Sprintf("\n\n## %s\n\n%s", title, content)
 	}
-	return stats, nil
+	for pkg := range s.PkgFiles {
+		links[pkg] = section(pkg, "Depends on", graph.Dependencies[pkg]) +
+			section(pkg, "Used by", graph.Dependents[pkg])
+	}
+	return links, nil
 }
 
-func writeFile(path, content string) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return err
+var linkSectionsRegexp = regexp.MustCompile(`(?:\n\n## (?:Depends on|Used by)\n\n(?:- \[[^\]\n]*\]\([^)\n]*\)\n)+)+$`)
+
+// refreshLinks replaces the trailing link sections of the existing readmes of the unchanged packages,
+// so they follow the packages added or removed since the readmes were written.
+// The readmes shared with the packages run and the project README are left to them
+func (s *PackageRunnerService) refreshLinks(
+	unchanged, pkgs []string, links map[string]string, projectReadme bool,
+) error {
+	written := map[string]bool{}
+	for _, pkg := range pkgs {
+		written[filepath.ToSlash(s.ProjectConfig.PackageDir(s.PkgFiles[pkg]))] = true
 	}
-	defer file.Close()
-	if _, err = file.WriteString(content); err != nil {
-		return err
+	output := s.output()
+	for _, pkg := range unchanged {
+		relDir := filepath.ToSlash(s.ProjectConfig.PackageDir(s.PkgFiles[pkg]))
+		if written[relDir] || relDir == "." && projectReadme {
+			continue
+		}
+		name, err := s.readmeName(relDir)
+		if err != nil {
+			return err
+		}
+		content, err := output.ReadFile(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		refreshed := linkSectionsRegexp.ReplaceAllLiteralString(string(content), "") + links[pkg]
+		if refreshed == string(content) {
+			continue
+		}
+		if err := output.WriteFile(name, []byte(refreshed)); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func getReadmePath(workdir string) (string, error) {
-	readmeFilename := "README_GENERATED.md"
-	if _, err := os.Stat(filepath.Join(workdir, "README.md")); err != nil {
-		if os.IsNotExist(err) {
-			readmeFilename = "README.md"
-		} else {
-			return "", err
+// output returns the project root output unless the Output is set
+func (s *PackageRunnerService) output() Output {
+	if s.Output != nil {
+		return s.Output
+	}
+	return DirOutput(s.ProjectConfig.RootPath)
+}
+
+// packageStructure lists the files of every project package
+func (s *PackageRunnerService) packageStructure() string {
+	content := "Project package structure:\n"
+	pkgs := slices.Sorted(maps.Keys(s.PkgFiles))
+	for _, pkg := range pkgs {
+		content += "- " + pkg + "\n"
+		for _, relPath := range s.PkgFiles[pkg] {
+			content += "  - " + relPath + "\n"
 		}
 	}
-	return readmeFilename, nil
+	return content
 }
 
 func fileMapToMd(fileMap map[string]string) string {
@@ -0,0 +1,245 @@
+package packagerunner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JackBekket/reflexia/pkg/project"
+	"github.com/JackBekket/reflexia/pkg/provider"
+	"github.com/JackBekket/reflexia/pkg/summarize"
+	"github.com/Swarmind/libagent/pkg/agent/simple"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// concurrencyModel records the max number of the concurrent requests of the fake model
+type concurrencyModel struct {
+	*provider.FakeModel
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (m *concurrencyModel) GenerateContent(
+	ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	m.mu.Lock()
+	m.inFlight++
+	m.maxInFlight = max(m.maxInFlight, m.inFlight)
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.inFlight--
+		m.mu.Unlock()
+	}()
+	time.Sleep(5 * time.Millisecond)
+	return m.FakeModel.GenerateContent(ctx, messages, options...)
+}
+
+func (m *concurrencyModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func TestRunPackagesParallelism(t *testing.T) {
+	root := t.TempDir()
+	pkgFiles := map[string][]string{}
+	for _, pkg := range []string{"a", "b", "c"} {
+		for i := range 4 {
+			relPath := filepath.Join(pkg, fmt.Sprintf("%d.go", i))
+			if err := os.MkdirAll(filepath.Join(root, pkg), os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(root, relPath), []byte("package "+pkg), 0644); err != nil {
+				t.Fatal(err)
+			}
+			pkgFiles[pkg] = append(pkgFiles[pkg], relPath)
+		}
+	}
+
+	for _, parallelism := range []int{0, 3} {
+		fake, err := provider.NewFakeModel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		model := &concurrencyModel{FakeModel: fake}
+		output := NewMemoryOutput()
+		service := &PackageRunnerService{
+			PkgFiles: pkgFiles,
+			ProjectConfig: &project.ProjectConfig{
+				ModuleMatch: "directory",
+				RootPath:    root,
+				Prompts: map[string]project.ProjectConfigPrompts{
+					"default": {CodePrompt: "code", PackagePrompt: "package"},
+				},
+			},
+			SummarizeService: &summarize.SummarizeService{
+				Agent:       &simple.Agent{LLM: model},
+				IgnoreCache: true,
+				Model:       provider.Fake,
+			},
+			Parallelism: parallelism,
+			Output:      output,
+			PrintTo:     io.Discard,
+		}
+		stats, err := service.RunPackages(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Usage.Requests != 15 || len(output.Names()) != 3 {
+			t.Fatalf("parallelism %d: unexpected %d requests, outputs %v", parallelism, stats.Usage.Requests, output.Names())
+		}
+		if model.maxInFlight > max(parallelism, 1) {
+			t.Errorf("parallelism %d: %d concurrent requests", parallelism, model.maxInFlight)
+		}
+	}
+}
+
+func TestPackageLinks(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(relPath, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, relPath)), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, relPath), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("go.mod", "module example.com/app\n")
+	writeFile("a/a.go", "package a\n\nimport \"example.com/app/b\"\n")
+	writeFile("b/b.go", "package b\n")
+
+	fake, err := provider.NewFakeModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := NewMemoryOutput()
+	run := func(exactPackages string, packages []string) {
+		t.Helper()
+		pc := &project.ProjectConfig{
+			FileFilter:  []string{".go"},
+			ModuleMatch: "go_package",
+			RootPath:    root,
+			Prompts: map[string]project.ProjectConfigPrompts{
+				"default": {CodePrompt: "code", PackagePrompt: "package"},
+			},
+		}
+		pkgFiles, err := pc.BuildPackageFiles()
+		if err != nil {
+			t.Fatal(err)
+		}
+		service := &PackageRunnerService{
+			PkgFiles:      pkgFiles,
+			ProjectConfig: pc,
+			SummarizeService: &summarize.SummarizeService{
+				Agent:       &simple.Agent{LLM: fake},
+				IgnoreCache: true,
+				Model:       provider.Fake,
+			},
+			ExactPackages: exactPackages,
+			Packages:      packages,
+			Output:        output,
+			PrintTo:       io.Discard,
+		}
+		if _, err := service.RunPackages(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	readme := func(name string) string {
+		t.Helper()
+		content, err := output.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+
+	// The readme of b is neither written nor existing
+	run("a:a", nil)
+	if strings.Contains(readme("a/README.md"), "## Depends on") {
+		t.Fatalf("unexpected link to the missing readme:\n%s", readme("a/README.md"))
+	}
+
+	run("", nil)
+	if !strings.HasSuffix(readme("a/README.md"), "\n\n## Depends on\n\n- [b:b](../b/README.md)\n") ||
+		!strings.HasSuffix(readme("b/README.md"), "\n\n## Used by\n\n- [a:a](../a/README.md)\n") {
+		t.Fatalf("unexpected links:\n%s\n%s", readme("a/README.md"), readme("b/README.md"))
+	}
+
+	// The link sections of the unchanged packages follow the changed imports
+	writeFile("a/a.go", "package a\n")
+	run("", []string{"a:a"})
+	if strings.Contains(readme("a/README.md"), "## Depends on") || strings.Contains(readme("b/README.md"), "## Used by") {
+		t.Fatalf("unexpected stale links:\n%s\n%s", readme("a/README.md"), readme("b/README.md"))
+	}
+}
+
+func TestReserveReadmes(t *testing.T) {
+	root := t.TempDir()
+	for relPath, content := range map[string]string{
+		"go.mod":            "module example.com/app\n",
+		"foo/foo.go":        "package foo\n",
+		"foo/foo_x_test.go": "package foo_test\n",
+		"foo/foo_y_test.go": "package foo_test\n",
+		"foo/bar/bar.go":    "package bar\n",
+		"foo/bar/README.md": "hand-written",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, relPath)), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, relPath), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pc := &project.ProjectConfig{
+		FileFilter:  []string{".go"},
+		ModuleMatch: "go_package",
+		RootPath:    root,
+		Prompts: map[string]project.ProjectConfigPrompts{
+			"default": {CodePrompt: "code", PackagePrompt: "package"},
+		},
+	}
+	pkgFiles, err := pc.BuildPackageFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, err := provider.NewFakeModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The packages of the same directory get the same distinct readmes on every parallel run
+	for range 5 {
+		service := &PackageRunnerService{
+			PkgFiles:      pkgFiles,
+			ProjectConfig: pc,
+			SummarizeService: &summarize.SummarizeService{
+				Agent:       &simple.Agent{LLM: fake},
+				IgnoreCache: true,
+				Model:       provider.Fake,
+			},
+			Parallelism: 4,
+			Output:      NewMemoryOutput(),
+			PrintTo:     io.Discard,
+		}
+		stats, err := service.RunPackages(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for pkg, name := range map[string]string{
+			"foo:foo":      "foo/README.md",
+			"foo:foo_test": "foo/README_GENERATED.md",
+			"foo/bar:bar":  "foo/bar/README_GENERATED.md",
+		} {
+			if outputs := stats.PackageOutputs[pkg]; len(outputs) != 1 || outputs[0] != name {
+				t.Fatalf("unexpected %s outputs %v", pkg, outputs)
+			}
+		}
+	}
+}
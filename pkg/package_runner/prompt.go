@@ -0,0 +1,78 @@
+package packagerunner
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// PromptData holds the variables of the text/template prompts, e.g.
+//
+//	Summarize the {{.Language}} file {{.FilePath}} of the {{.Package}} package:
+//	```
+//	{{.Content}}
+//	```
+//
+// Prompts without the template actions are plain prompts,
+// the request content is appended to them as before
+type PromptData struct {
+	// Language is the project config name without the extension, e.g. "go" or "ts"
+	Language string
+	// Package is the package key, empty for the project prompt
+	Package string
+	// FilePath is the project root relative file path of the code and chunk_reduce prompts
+	FilePath string
+	// Content is the file content or the file chunk content of the code prompt
+	Content string
+	// Part and Parts are the 1-based chunk number and the chunks count
+	// of the oversized file code prompt, zero for the whole file
+	Part  int
+	Parts int
+	// Imports are the imports of the file for the code prompt,
+	// or the imports of all package files for the package prompt
+	Imports []string
+	// FileSummaries maps the file path to its summary for the package prompt
+	// and the file part summaries for the chunk_reduce prompt
+	FileSummaries map[string]string
+	// PackageSummaries maps the package key to its summary for the project prompt
+	PackageSummaries map[string]string
+	// Structure is the package directory structure for the package prompt
+	// and the project package structure for the project prompt
+	Structure string
+}
+
+// prompt is the plain prompt text or the parsed template
+type prompt struct {
+	text string
+	tmpl *template.Template
+}
+
+func newPrompt(name, text string) (prompt, error) {
+	p := prompt{text: text}
+	if !strings.Contains(text, "{{") {
+		return p, nil
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return p, fmt.Errorf("parse %s prompt template: %w", name, err)
+	}
+	p.tmpl = tmpl
+	return p, nil
+}
+
+func (p prompt) empty() bool {
+	return p.text == ""
+}
+
+// render executes the template prompt with the data,
+// the plain prompt is formatted as the first argument of the format
+func (p prompt) render(data PromptData, format string, a ...any) (string, error) {
+	if p.tmpl == nil {
+		return fmt.Sprintf(format, append([]any{p.text}, a...)...), nil
+	}
+	var b strings.Builder
+	if err := p.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("execute %s prompt template: %w", p.tmpl.Name(), err)
+	}
+	return b.String(), nil
+}
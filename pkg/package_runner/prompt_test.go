@@ -0,0 +1,82 @@
+package packagerunner
+
+import (
+	"testing"
+
+	"github.com/JackBekket/reflexia/pkg/project"
+)
+
+func TestPromptRender(t *testing.T) {
+	data := PromptData{
+		Language: "go",
+		Package:  "util",
+		FilePath: "util/util.go",
+		Content:  "package util",
+		Imports:  []string{"fmt", "os"},
+	}
+
+	plain, err := newPrompt("default.code", "Summarize the code:\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := plain.render(data, "%s```\n%s\n```", data.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request != "Summarize the code:\n```\npackage util\n```" {
+		t.Errorf("unexpected plain prompt request %q", request)
+	}
+
+	templated, err := newPrompt("default.code",
+		"{{.Language}} file {{.FilePath}} of {{.Package}}{{range .Imports}} {{.}}{{end}}:\n{{.Content}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err = templated.render(data, "%s```\n%s\n```", data.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request != "go file util/util.go of util fmt os:\npackage util" {
+		t.Errorf("unexpected template prompt request %q", request)
+	}
+
+	if _, err := newPrompt("default.code", "{{.Content"); err == nil {
+		t.Error("expected template parse error")
+	}
+	unknown, err := newPrompt("default.code", "{{.Unknown}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unknown.render(data, "%s"); err == nil {
+		t.Error("expected unknown variable error")
+	}
+}
+
+func TestPathPrompts(t *testing.T) {
+	service := &PackageRunnerService{ProjectConfig: &project.ProjectConfig{
+		Prompts: map[string]project.ProjectConfigPrompts{
+			"default": {CodePrompt: "code", PackagePrompt: "package"},
+		},
+		PathPrompts: []project.ProjectConfigPathPrompts{
+			{Paths: []string{"internal/"}, Prompts: project.ProjectConfigPrompts{CodePrompt: "internal code"}},
+			{Paths: []string{"*_test.go"}, Prompts: project.ProjectConfigPrompts{CodePrompt: "test code"}},
+			{Paths: []string{"api/"}, Prompts: project.ProjectConfigPrompts{PackagePrompt: "api package"}},
+		},
+	}}
+	prompts, err := service.choosePrompts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string][2]string{
+		"main.go":                  {"code", "package"},
+		"internal/util/util.go":    {"internal code", "package"},
+		"internal/util/ut_test.go": {"test code", "package"},
+		"api/":                     {"code", "api package"},
+	} {
+		pathPrompts := prompts.forPath(path)
+		if pathPrompts.codePrompt.text != expected[0] || pathPrompts.packagePrompt.text != expected[1] {
+			t.Errorf("unexpected %s prompts %q, %q", path,
+				pathPrompts.codePrompt.text, pathPrompts.packagePrompt.text)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package project
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"maps"
+	"os"
 	"slices"
 	"sort"
 	"strconv"
@@ -12,11 +14,25 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// ErrNoProjectConfig is returned by the choosers if no project config matches the project
+var ErrNoProjectConfig = errors.New("failed to detect project language")
+
+// NoProjectConfigError returns ErrNoProjectConfig listing the project configs loaded for the currentDirectory
+func NoProjectConfigError(currentDirectory string) error {
+	projectConfigs, err := ListProjectConfigs(currentDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: list project configs: %w", ErrNoProjectConfig, err)
+	}
+	languages := []string{}
+	for _, name := range slices.Sorted(maps.Keys(projectConfigs)) {
+		languages = append(languages, strings.TrimSuffix(name, ".toml"))
+	}
+	return fmt.Errorf("%w, available languages: %s", ErrNoProjectConfig, strings.Join(languages, ", "))
+}
+
 func FirstChooser(projectConfigVariants map[string]*ProjectConfig) (*ProjectConfig, error) {
 	if len(projectConfigVariants) == 0 {
-		return nil, errors.New(
-			"failed to detect project language, available languages: go, python, typescript",
-		)
+		return nil, ErrNoProjectConfig
 	}
 	keys := slices.Collect(maps.Keys(projectConfigVariants))
 	sort.Strings(keys)
@@ -26,33 +42,39 @@ func FirstChooser(projectConfigVariants map[string]*ProjectConfig) (*ProjectConf
 func CLIChooser(projectConfigVariants map[string]*ProjectConfig) (*ProjectConfig, error) {
 	switch len(projectConfigVariants) {
 	case 0:
-		return nil, errors.New(
-			"failed to detect project language, available languages: go, python, typescript",
-		)
+		return nil, ErrNoProjectConfig
 	case 1:
 		for _, pc := range projectConfigVariants {
 			return pc, nil
 		}
 	default:
-		var filenames []string
-		for filename := range projectConfigVariants {
-			filenames = append(filenames, filename)
+		scores, err := RankProjectConfigs(projectConfigVariants)
+		if err != nil {
+			return nil, fmt.Errorf("rank project configs: %w", err)
 		}
-		fmt.Println("Multiple project config matches found!")
-		for i, filename := range filenames {
-			fmt.Printf("%d. %v\n", i+1, filename)
+		fmt.Println("Multiple project config matches found, ranked by score:")
+		for i, score := range scores {
+			fmt.Printf("%d. %v\n", i+1, score)
 		}
-		fmt.Print("Enter the number or filename: ")
+		fmt.Print("Enter the number or filename [1]: ")
+		reader := bufio.NewReader(os.Stdin)
 		for {
-			var input string
-			if _, err := fmt.Scanln(&input); err != nil {
-				log.Fatal().Err(err).Msg("scanln")
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				log.Fatal().Err(err).Msg("read stdin")
+			}
+			input := strings.TrimSpace(line)
+			if input == "" {
+				input = "1"
 			}
-			if index, err := strconv.Atoi(input); err == nil && index > 0 && index <= len(filenames) {
-				return projectConfigVariants[filenames[index-1]], nil
+			if index, err := strconv.Atoi(input); err == nil && index > 0 && index <= len(scores) {
+				pc := projectConfigVariants[scores[index-1].Name]
+				pc.Ranking = scores
+				return pc, nil
 			} else {
 				for filename, config := range projectConfigVariants {
 					if filename == input || strings.TrimSuffix(filename, ".toml") == input {
+						config.Ranking = scores
 						return config, nil
 					}
 				}
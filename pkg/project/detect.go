@@ -0,0 +1,101 @@
+package project
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/JackBekket/reflexia/internal/util"
+)
+
+// DetectProjects finds every project config matching the workdir or its subdirectories,
+// e.g. go.toml for backend/go.mod and ts.toml for web/package.json, with RootPath set to the project root.
+// A directory is a project root of the config if it has one of the config root filter files
+// and the config filter files. Nested roots of the same config are documented as a part of the outer one,
+// such as the workspace packages. The light check matches the configs with the filter files in the workdir only.
+// withConfigFile limits the detection to that config
+func DetectProjects(workdir, withConfigFile string, lightCheck bool) ([]*ProjectConfig, error) {
+	if lightCheck {
+		variants, err := GetProjectConfig(workdir, withConfigFile, lightCheck)
+		if err != nil {
+			return nil, err
+		}
+		return sortProjects(variants), nil
+	}
+
+	_, projectConfigs, err := loadProjectConfigs(workdir, withConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("load project configs: %w", err)
+	}
+	if withConfigFile != "" {
+		if projectConfigs, err = withConfigFileOnly(projectConfigs, withConfigFile); err != nil {
+			return nil, err
+		}
+	}
+
+	roots := map[string][]string{}
+	if err := util.WalkDirIgnored(
+		workdir,
+		filepath.Join(workdir, ".gitignore"),
+		func(path string, d fs.DirEntry) error {
+			if !d.IsDir() {
+				return nil
+			}
+			if d.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			for name, config := range projectConfigs {
+				for _, filter := range config.ProjectRootFilter {
+					if isFile(filepath.Join(path, filter)) {
+						roots[name] = append(roots[name], filepath.Clean(path))
+						break
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+		return nil, err
+	}
+
+	projects := map[string]*ProjectConfig{}
+	for name, configRoots := range roots {
+		// WalkDir visits the outer directories first
+		topmost := []string{}
+		for _, root := range configRoots {
+			if slices.ContainsFunc(topmost, func(outer string) bool {
+				return strings.HasPrefix(root, outer+string(filepath.Separator))
+			}) {
+				continue
+			}
+			topmost = append(topmost, root)
+		}
+
+		for _, root := range topmost {
+			config := projectConfigs[name]
+			found, err := hasFilterFiles(root, config.FileFilter)
+			if err != nil {
+				return nil, fmt.Errorf("has filter files: %w", err)
+			}
+			if !found {
+				continue
+			}
+			config.RootPath = root
+			projects[root+string(filepath.Separator)+name] = &config
+		}
+	}
+	return sortProjects(projects), nil
+}
+
+func sortProjects(projects map[string]*ProjectConfig) []*ProjectConfig {
+	sorted := []*ProjectConfig{}
+	for _, config := range projects {
+		sorted = append(sorted, config)
+	}
+	slices.SortFunc(sorted, func(a, b *ProjectConfig) int {
+		return cmp.Or(cmp.Compare(a.RootPath, b.RootPath), cmp.Compare(a.Name, b.Name))
+	})
+	return sorted
+}
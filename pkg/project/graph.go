@@ -0,0 +1,205 @@
+package project
+
+import (
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// PackageGraph is the import graph between the packages of the project
+type PackageGraph struct {
+	// Dependencies are the sorted project packages imported by the package
+	Dependencies map[string][]string
+	// Dependents are the sorted project packages importing the package
+	Dependents map[string][]string
+}
+
+// BuildPackageGraph resolves the imports of the package files to the other packages of the project:
+// the go.mod module imports of go_package, the absolute and relative module imports of python_package,
+// the workspace package names and relative paths of ts_workspace.
+// The imports of the other module match modes are not resolved
+func (pc *ProjectConfig) BuildPackageGraph(packageFiles map[string][]string) (PackageGraph, error) {
+	graph := PackageGraph{Dependencies: map[string][]string{}, Dependents: map[string][]string{}}
+
+	var resolve func(pkg, relPath, imp string) string
+	var err error
+	switch pc.ModuleMatch {
+	case "go_package":
+		resolve, err = pc.goImportResolver(packageFiles)
+	case "python_package":
+		resolve, err = pc.pythonImportResolver(packageFiles)
+	case "ts_workspace":
+		resolve, err = pc.tsImportResolver(packageFiles)
+	default:
+		return graph, nil
+	}
+	if err != nil || resolve == nil {
+		return graph, err
+	}
+
+	for _, pkg := range slices.Sorted(maps.Keys(packageFiles)) {
+		for _, relPath := range packageFiles[pkg] {
+			imports, cached := pc.goImports[relPath]
+			if !cached {
+				content, err := os.ReadFile(filepath.Join(pc.RootPath, relPath))
+				if err != nil {
+					return graph, err
+				}
+				imports = FileImports(relPath, string(content))
+			}
+			for _, imp := range imports {
+				dependency := resolve(pkg, filepath.ToSlash(relPath), imp)
+				if dependency == "" || dependency == pkg || slices.Contains(graph.Dependencies[pkg], dependency) {
+					continue
+				}
+				graph.Dependencies[pkg] = append(graph.Dependencies[pkg], dependency)
+				graph.Dependents[dependency] = append(graph.Dependents[dependency], pkg)
+			}
+		}
+		slices.Sort(graph.Dependencies[pkg])
+	}
+	for _, dependents := range graph.Dependents {
+		slices.Sort(dependents)
+	}
+	return graph, nil
+}
+
+var goModuleRegexp = regexp.MustCompile(`(?m)^\s*module\s+"?([^\s"]+)"?`)
+
+// goImportResolver resolves the imports under the go.mod module path to the non-test package of the directory
+func (pc *ProjectConfig) goImportResolver(packageFiles map[string][]string) (func(pkg, relPath, imp string) string, error) {
+	content, err := os.ReadFile(filepath.Join(pc.RootPath, "go.mod"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	match := goModuleRegexp.FindSubmatch(content)
+	if match == nil {
+		return nil, nil
+	}
+	module := string(match[1])
+
+	dirPackages := map[string]string{}
+	for _, pkg := range slices.Sorted(maps.Keys(packageFiles)) {
+		i := strings.LastIndex(pkg, ":")
+		dir, name := pkg[:max(i, 0)], pkg[i+1:]
+		if _, exists := dirPackages[filepath.ToSlash(dir)]; !exists && !strings.HasSuffix(name, "_test") {
+			dirPackages[filepath.ToSlash(dir)] = pkg
+		}
+	}
+	return func(_, _, imp string) string {
+		if imp == module {
+			return dirPackages["."]
+		}
+		if dir, found := strings.CutPrefix(imp, module+"/"); found {
+			return dirPackages[dir]
+		}
+		return ""
+	}, nil
+}
+
+// pythonImportResolver resolves the dotted module imports, relative to the importing package for the leading dots,
+// to the package of the longest matching module path
+func (pc *ProjectConfig) pythonImportResolver(packageFiles map[string][]string) (func(pkg, relPath, imp string) string, error) {
+	roots, _, err := pythonSourceRoots(pc.RootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Top-level modules of the source roots are grouped under the source root path key
+	sourceRootKey := func(pkg string) bool {
+		return slices.ContainsFunc(roots, func(root pythonSourceRoot) bool {
+			return root.prefix == "" && filepath.ToSlash(root.dir) == pkg
+		})
+	}
+	modules := map[string]string{}
+	for _, pkg := range slices.Sorted(maps.Keys(packageFiles)) {
+		sourceRoot := sourceRootKey(pkg)
+		if !sourceRoot {
+			modules[pkg] = pkg
+		}
+		for _, relPath := range packageFiles[pkg] {
+			name := strings.TrimSuffix(filepath.Base(relPath), filepath.Ext(relPath))
+			if sourceRoot {
+				modules[name] = pkg
+			} else if name != "__init__" {
+				modules[pkg+"."+name] = pkg
+			}
+		}
+	}
+
+	return func(pkg, _, imp string) string {
+		if strings.HasPrefix(imp, ".") {
+			if sourceRootKey(pkg) {
+				return ""
+			}
+			rest := strings.TrimLeft(imp, ".")
+			parts := strings.Split(pkg, ".")
+			up := len(imp) - len(rest) - 1
+			if up > len(parts)-1 {
+				return ""
+			}
+			parts = parts[:len(parts)-up]
+			if rest != "" {
+				parts = append(parts, rest)
+			}
+			imp = strings.Join(parts, ".")
+		}
+		parts := strings.Split(imp, ".")
+		for i := len(parts); i > 0; i-- {
+			if dependency, exists := modules[strings.Join(parts[:i], ".")]; exists {
+				return dependency
+			}
+		}
+		return ""
+	}, nil
+}
+
+var tsImportExtensions = []string{"", ".ts", ".tsx", ".js", ".jsx", ".mjs", "/index.ts", "/index.tsx", "/index.js", "/index.jsx"}
+
+// tsImportResolver resolves the relative imports to the package of the imported file
+// and the workspace package names, including their subpath imports
+func (pc *ProjectConfig) tsImportResolver(packageFiles map[string][]string) (func(pkg, relPath, imp string) string, error) {
+	workspaces, err := tsWorkspacePackages(pc.RootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	filePackages := map[string]string{}
+	for pkg, files := range packageFiles {
+		for _, relPath := range files {
+			filePackages[filepath.ToSlash(relPath)] = pkg
+		}
+	}
+	return func(_, relPath, imp string) string {
+		if strings.HasPrefix(imp, "./") || strings.HasPrefix(imp, "../") {
+			target := path.Join(path.Dir(relPath), imp)
+			// The ES module imports of the TypeScript files use the .js extension
+			trimmed := strings.TrimSuffix(target, path.Ext(target))
+			for _, ext := range tsImportExtensions {
+				if dependency, exists := filePackages[target+ext]; exists {
+					return dependency
+				}
+				if dependency, exists := filePackages[trimmed+ext]; exists && ext != "" {
+					return dependency
+				}
+			}
+			return ""
+		}
+		for _, workspace := range workspaces {
+			if imp == workspace.name || strings.HasPrefix(imp, workspace.name+"/") {
+				if _, exists := packageFiles[workspace.name]; exists {
+					return workspace.name
+				}
+			}
+		}
+		return ""
+	}, nil
+}
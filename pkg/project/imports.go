@@ -0,0 +1,74 @@
+package project
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var importRegexps = map[string]*regexp.Regexp{
+	".py":   regexp.MustCompile(`(?m)^\s*(?:from\s+([\w.]+)\s+import|import\s+([\w.]+(?:\s+as\s+\w+)?(?:[ \t]*,[ \t]*[\w.]+(?:\s+as\s+\w+)?)*))`),
+	".ts":   regexp.MustCompile(`(?m)(?:^\s*import\s[^;]*?from\s+|^\s*import\s+|require\()['"]([^'"]+)['"]`),
+	".rs":   regexp.MustCompile(`(?m)^\s*(?:pub\s+)?use\s+([\w:]+)`),
+	".java": regexp.MustCompile(`(?m)^\s*import\s+(?:static\s+)?([\w.]+)`),
+	".c":    regexp.MustCompile(`(?m)^\s*#\s*include\s+[<"]([^>"]+)[>"]`),
+}
+
+func init() {
+	for _, ext := range []string{".tsx", ".js", ".jsx", ".mjs"} {
+		importRegexps[ext] = importRegexps[".ts"]
+	}
+	importRegexps[".kt"] = importRegexps[".java"]
+	for _, ext := range []string{".h", ".cpp", ".hpp", ".cc", ".cu", ".cuh"} {
+		importRegexps[ext] = importRegexps[".c"]
+	}
+}
+
+// FileImports returns the sorted imported packages, modules or headers of the source file,
+// nil for the unknown languages
+func FileImports(relPath, content string) []string {
+	imports := []string{}
+	ext := filepath.Ext(relPath)
+	if ext == ".go" {
+		file, err := parser.ParseFile(token.NewFileSet(), relPath, content, parser.ImportsOnly)
+		if err != nil {
+			return nil
+		}
+		imports = goFileImports(file)
+	} else if re, exists := importRegexps[ext]; exists {
+		for _, match := range re.FindAllStringSubmatch(content, -1) {
+			for _, group := range match[1:] {
+				if group == "" {
+					continue
+				}
+				if ext != ".py" {
+					imports = append(imports, group)
+					continue
+				}
+				// The python import statement lists the modules with the optional aliases
+				for _, module := range strings.Split(group, ",") {
+					imports = append(imports, strings.Fields(module)[0])
+				}
+			}
+		}
+	} else {
+		return nil
+	}
+	slices.Sort(imports)
+	return slices.Compact(imports)
+}
+
+func goFileImports(file *ast.File) []string {
+	imports := []string{}
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imports = append(imports, path)
+		}
+	}
+	return imports
+}
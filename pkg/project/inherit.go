@@ -0,0 +1,282 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	projectconfig "github.com/JackBekket/reflexia/project_config"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// configDoc is the raw toml document of the project config file
+type configDoc struct {
+	file ConfigFile
+	doc  map[string]any
+	// positions maps the formatted key, e.g. prompts."qwen3.*".code, to its position
+	positions map[string]unstable.Position
+}
+
+// mergedDoc is the document merged with its extends and include references
+type mergedDoc struct {
+	values map[string]any
+	// origins maps the formatted key to the position of the file it is merged from
+	origins  map[string]ValidationError
+	inherits []string
+}
+
+// isPartialConfig reports whether the toml file is only a base for the extends and include keys
+// rather than a project config, such as _base.toml
+func isPartialConfig(name string) bool {
+	return strings.HasPrefix(name, "_")
+}
+
+func readConfigDoc(file ConfigFile) (configDoc, error) {
+	var (
+		content []byte
+		err     error
+	)
+	if file.Source == SourceEmbedded {
+		content, err = fs.ReadFile(projectconfig.FS, filepath.ToSlash(file.Path))
+	} else {
+		content, err = os.ReadFile(file.Path)
+	}
+	if err != nil {
+		return configDoc{}, err
+	}
+	return parseConfigDoc(file, content)
+}
+
+// parseConfigDoc returns the ValidationError with the position of the toml syntax error
+func parseConfigDoc(file ConfigFile, content []byte) (configDoc, error) {
+	doc := map[string]any{}
+	if err := toml.Unmarshal(content, &doc); err != nil {
+		problem := ValidationError{Path: file.Path, Message: err.Error()}
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			problem.Line, problem.Column = decodeErr.Position()
+			problem.Key = formatKey(decodeErr.Key())
+		}
+		return configDoc{}, problem
+	}
+	return configDoc{file: file, doc: doc, positions: keyPositions(content)}, nil
+}
+
+// keyPositions returns the positions of the table headers and keys of the valid toml document
+func keyPositions(content []byte) map[string]unstable.Position {
+	positions := map[string]unstable.Position{}
+	parser := unstable.Parser{}
+	parser.Reset(content)
+	table := []string{}
+	for parser.NextExpression() {
+		expression := parser.Expression()
+		key := []string{}
+		nodes := []*unstable.Node{}
+		for it := expression.Key(); it.Next(); {
+			key = append(key, string(it.Node().Data))
+			nodes = append(nodes, it.Node())
+		}
+		if len(key) == 0 {
+			continue
+		}
+		prefix := []string{}
+		switch expression.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			table = key
+		case unstable.KeyValue:
+			prefix = table
+		}
+		// Every part of the dotted key is positioned at itself
+		for i, node := range nodes {
+			formatted := formatKey(append(slices.Clone(prefix), key[:i+1]...))
+			if _, exists := positions[formatted]; !exists {
+				positions[formatted] = parser.Shape(node.Raw).Start
+			}
+		}
+	}
+	return positions
+}
+
+var bareKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// formatKey joins the key parts with dots quoting the non-bare ones
+func formatKey(key []string) string {
+	parts := make([]string, len(key))
+	for i, part := range key {
+		if bareKeyRegexp.MatchString(part) {
+			parts[i] = part
+		} else {
+			parts[i] = fmt.Sprintf("%q", part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// problem returns the ValidationError positioned at the key of the document
+func (d configDoc) problem(key, format string, a ...any) ValidationError {
+	position := d.positions[key]
+	return ValidationError{
+		Path:    d.file.Path,
+		Line:    position.Line,
+		Column:  position.Column,
+		Key:     key,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+// configResolver merges the project config documents with their extends and include references.
+// docs are the search path documents in the order of increasing precedence
+type configResolver struct {
+	docs  []configDoc
+	stack []string
+}
+
+// resolve returns the merged document of docs[index] or of the referenced doc if index is negative.
+// The extended config is merged first, then the included ones in order and the doc itself last
+func (r *configResolver) resolve(doc configDoc, index int) (mergedDoc, error) {
+	stackKey := doc.file.Source + ":" + doc.file.Path
+	if slices.Contains(r.stack, stackKey) {
+		return mergedDoc{}, doc.problem("extends",
+			"inheritance cycle %s -> %s", strings.Join(r.stack, " -> "), stackKey,
+		)
+	}
+	r.stack = append(r.stack, stackKey)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	type reference struct{ key, ref string }
+	refs := []reference{}
+	if extends, exists := doc.doc["extends"]; exists {
+		ref, ok := extends.(string)
+		if !ok {
+			return mergedDoc{}, doc.problem("extends", "extends must be a string")
+		}
+		refs = append(refs, reference{"extends", ref})
+	}
+	if include, exists := doc.doc["include"]; exists {
+		items, ok := include.([]any)
+		if !ok {
+			return mergedDoc{}, doc.problem("include", "include must be an array of strings")
+		}
+		for _, item := range items {
+			ref, ok := item.(string)
+			if !ok {
+				return mergedDoc{}, doc.problem("include", "include must be an array of strings")
+			}
+			refs = append(refs, reference{"include", ref})
+		}
+	}
+
+	merged := mergedDoc{values: map[string]any{}, origins: map[string]ValidationError{}, inherits: []string{}}
+	for _, ref := range refs {
+		parent, parentIndex, err := r.lookup(doc, index, ref.ref)
+		if err != nil {
+			var problem ValidationError
+			if errors.As(err, &problem) {
+				return mergedDoc{}, problem
+			}
+			return mergedDoc{}, doc.problem(ref.key, "%v", err)
+		}
+		parentMerged, err := r.resolve(parent, parentIndex)
+		if err != nil {
+			return mergedDoc{}, err
+		}
+		merged.values = mergeConfigDocs(merged.values, parentMerged.values)
+		for key, origin := range parentMerged.origins {
+			merged.origins[key] = origin
+		}
+		merged.inherits = append(merged.inherits, parentMerged.inherits...)
+		merged.inherits = append(merged.inherits, parent.file.Path)
+	}
+
+	own := map[string]any{}
+	for key, value := range doc.doc {
+		if key != "extends" && key != "include" {
+			own[key] = value
+		}
+	}
+	merged.values = mergeConfigDocs(merged.values, own)
+	for key := range doc.positions {
+		merged.origins[key] = doc.problem(key, "")
+	}
+	return merged, nil
+}
+
+// lookup finds the referenced config document.
+// A reference with a path separator is a path relative to the referencing file.
+// A config name is looked up in the search path from the highest precedence source,
+// the own name refers to the same named config of the lower precedence sources,
+// so the user config can extend the embedded default it overrides.
+// Names missing in the search path are read next to the referencing file.
+// The absolute paths and the paths escaping the directory of the referencing file are rejected
+func (r *configResolver) lookup(doc configDoc, index int, ref string) (configDoc, int, error) {
+	if !strings.ContainsAny(ref, `/\`) {
+		candidates := r.docs
+		if ref == doc.file.Name && index >= 0 {
+			candidates = r.docs[:index]
+		}
+		for i := len(candidates) - 1; i >= 0; i-- {
+			if candidates[i].file.Name == ref && candidates[i].file.Path != doc.file.Path {
+				return candidates[i], i, nil
+			}
+		}
+	}
+
+	if !filepath.IsLocal(filepath.FromSlash(strings.ReplaceAll(ref, `\`, "/"))) {
+		return configDoc{}, 0, fmt.Errorf("%s is not a path inside the %s directory", ref, filepath.Dir(doc.file.Path))
+	}
+	file := ConfigFile{Name: path.Base(filepath.ToSlash(ref)), Source: doc.file.Source}
+	if doc.file.Source == SourceEmbedded {
+		file.Path = path.Join(path.Dir(filepath.ToSlash(doc.file.Path)), ref)
+	} else {
+		file.Path = filepath.Join(filepath.Dir(doc.file.Path), filepath.FromSlash(ref))
+	}
+	parent, err := readConfigDoc(file)
+	if err != nil {
+		return configDoc{}, 0, fmt.Errorf("resolve %s: %w", ref, err)
+	}
+	return parent, -1, nil
+}
+
+// mergeConfigDocs returns a copy of base with the tables of override merged recursively,
+// the other override values such as strings and arrays replace the base ones
+func mergeConfigDocs(base, override map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		overrideTable, isTable := value.(map[string]any)
+		baseTable, baseIsTable := merged[key].(map[string]any)
+		if isTable && baseIsTable {
+			merged[key] = mergeConfigDocs(baseTable, overrideTable)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// decodeConfigDoc decodes the merged document into the ProjectConfig
+func decodeConfigDoc(file ConfigFile, doc map[string]any) (ProjectConfig, error) {
+	var config ProjectConfig
+	content, err := toml.Marshal(doc)
+	if err != nil {
+		return config, fmt.Errorf("encode %s project config %s: %w", file.Source, file.Path, err)
+	}
+	if err := toml.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("parse %s project config %s: %w", file.Source, file.Path, err)
+	}
+	return config, nil
+}
+
+// MarshalMerged encodes the project config with the extends and include references resolved
+func (pc *ProjectConfig) MarshalMerged() ([]byte, error) {
+	return toml.Marshal(pc)
+}
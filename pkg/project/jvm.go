@@ -0,0 +1,41 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var jvmPackageRegexp = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)`)
+
+// buildJVMPackageFiles groups the Java and Kotlin files by the declared package statement,
+// so the src/main and src/test files of the same package are documented together.
+// Keys are "module:package" where the module is the directory preceding the src directory,
+// "." for the project root one, files without package statement belong to the "default" package
+func (pc *ProjectConfig) buildJVMPackageFiles() (map[string][]string, error) {
+	packageFileMap := map[string][]string{}
+	if err := pc.walkFilterFiles(func(relPath string) error {
+		content, err := os.ReadFile(filepath.Join(pc.RootPath, relPath))
+		if err != nil {
+			return err
+		}
+		pkg := "default"
+		if match := jvmPackageRegexp.FindSubmatch(content); match != nil {
+			pkg = string(match[1])
+		}
+
+		module := "."
+		parts := strings.Split(filepath.ToSlash(filepath.Dir(relPath)), "/")
+		if i := slices.Index(parts, "src"); i > 0 {
+			module = strings.Join(parts[:i], "/")
+		}
+		key := module + ":" + pkg
+		packageFileMap[key] = append(packageFileMap[key], relPath)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return packageFileMap, nil
+}
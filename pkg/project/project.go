@@ -1,6 +1,7 @@
 package project
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"go/parser"
@@ -9,55 +10,110 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/JackBekket/reflexia/internal/util"
-
-	"github.com/pelletier/go-toml/v2"
+	ignore "github.com/crackcomm/go-gitignore"
 )
 
 type ProjectConfig struct {
 	FileFilter        []string                        `toml:"file_filter"`
 	ProjectRootFilter []string                        `toml:"project_root_filter"`
-	ModuleMatch       string                          `toml:"module_match"`
+	ModuleMatch       string                          `toml:"module_match,omitempty"`
 	StopWords         []string                        `toml:"stop_words"`
+	Chunking          ProjectConfigChunking           `toml:"chunking"`
 	Prompts           map[string]ProjectConfigPrompts `toml:"prompts"`
+	// Exclude are the .gitignore syntax patterns of the project root relative paths not to document
+	Exclude     []string                   `toml:"exclude,omitempty"`
+	Output      ProjectConfigOutput        `toml:"output,omitempty"`
+	PathPrompts []ProjectConfigPathPrompts `toml:"path_prompts,omitempty"`
+
+	RootPath string `toml:"-"`
+	// Name is the project config filename
+	Name string `toml:"-"`
+	// Source and Path describe where the config is loaded from, see ConfigSearchPath
+	Source string `toml:"-"`
+	Path   string `toml:"-"`
+	// Inherits are the paths of the extended and included configs in the merge order
+	Inherits []string `toml:"-"`
+	// Ranking explains why the config is chosen among the candidates, see ScoreChooser
+	Ranking []ConfigScore `toml:"-"`
+	// RepositoryConfig is the path of the applied .reflexia.toml, see ApplyRepositoryConfig
+	RepositoryConfig string `toml:"-"`
 
-	RootPath string
+	// goImports are the imports of the go_package files parsed by BuildPackageFiles for BuildPackageGraph
+	goImports map[string][]string
 }
 
 type ProjectConfigPrompts struct {
-	CodePrompt            string  `toml:"code"`
-	CodePromptFallback    *string `toml:"code_fallback"`
-	PackagePrompt         string  `toml:"package"`
-	PackagePromptFallback *string `toml:"package_fallback"`
+	CodePrompt            string  `toml:"code,multiline,omitempty"`
+	CodePromptFallback    *string `toml:"code_fallback,multiline,omitempty"`
+	PackagePrompt         string  `toml:"package,multiline,omitempty"`
+	PackagePromptFallback *string `toml:"package_fallback,multiline,omitempty"`
+	ChunkReducePrompt     string  `toml:"chunk_reduce,multiline,omitempty"`
+	// ProjectPrompt summarizes the package summaries into the project root README,
+	// the project stage is skipped if it is empty
+	ProjectPrompt         string  `toml:"project,multiline,omitempty"`
+	ProjectPromptFallback *string `toml:"project_fallback,multiline,omitempty"`
+}
+
+// ProjectConfigOutput sets the generated filenames, the defaults are used for the empty ones
+type ProjectConfigOutput struct {
+	Readme          string `toml:"readme,omitempty"`
+	GeneratedReadme string `toml:"generated_readme,omitempty"`
+	FileSummary     string `toml:"file_summary,omitempty"`
+}
+
+// ReadmeFilename is README.md by default
+func (o ProjectConfigOutput) ReadmeFilename() string {
+	return cmp.Or(o.Readme, "README.md")
 }
 
+// GeneratedReadmeFilename is written instead of the existing readme unless it is overwritten,
+// README_GENERATED.md by default
+func (o ProjectConfigOutput) GeneratedReadmeFilename() string {
+	return cmp.Or(o.GeneratedReadme, "README_GENERATED.md")
+}
+
+// FileSummaryFilename is FILES.md by default
+func (o ProjectConfigOutput) FileSummaryFilename() string {
+	return cmp.Or(o.FileSummary, "FILES.md")
+}
+
+// ProjectConfigPathPrompts override the non-empty prompts for the files and package directories
+// matching the .gitignore syntax Paths, the later matching entries take precedence
+type ProjectConfigPathPrompts struct {
+	Paths   []string             `toml:"paths"`
+	Prompts ProjectConfigPrompts `toml:"prompts"`
+}
+
+// ProjectConfigChunking controls splitting of the oversized files,
+// files with estimated token count above MaxTokens are summarized
+// by ChunkTokens sized chunks which are then reduced into one summary.
+// Zero MaxTokens disables chunking
+type ProjectConfigChunking struct {
+	MaxTokens   int `toml:"max_tokens"`
+	ChunkTokens int `toml:"chunk_tokens"`
+}
+
+// GetProjectConfig returns the project configs matching the currentDirectory,
+// or the withConfigFile config, either a path to the toml file or a config name in the search path
 func GetProjectConfig(
 	currentDirectory, withConfigFile string, lightCheck bool,
 ) (map[string]*ProjectConfig, error) {
-	if _, err := os.Stat("project_config"); os.IsNotExist(err) {
-		if _, err := os.Stat(withConfigFile); err == nil {
-			content, err := os.ReadFile(withConfigFile)
-			if err != nil {
-				return nil, err
-			}
-			var config ProjectConfig
-			if err = toml.Unmarshal(content, &config); err != nil {
-				return nil, err
-			}
-			config.RootPath = currentDirectory
-			return map[string]*ProjectConfig{withConfigFile: &config}, nil
-		}
-	}
-
-	projectConfigs, err := ListProjectConfigs(currentDirectory)
+	_, projectConfigs, err := loadProjectConfigs(currentDirectory, withConfigFile)
 	if err != nil {
-		return nil, fmt.Errorf("list project configs: %w", err)
+		return nil, fmt.Errorf("load project configs: %w", err)
 	}
 
-	if config, exists := projectConfigs[filepath.Base(withConfigFile)]; exists {
-		return map[string]*ProjectConfig{withConfigFile: &config}, nil
+	if withConfigFile != "" {
+		projectConfigs, err = withConfigFileOnly(projectConfigs, withConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		config := projectConfigs[filepath.Base(withConfigFile)]
+		return map[string]*ProjectConfig{config.Name: &config}, nil
 	}
 
 	var projectConfigVariants = map[string]*ProjectConfig{}
@@ -83,96 +139,55 @@ func GetProjectConfig(
 	return projectConfigVariants, nil
 }
 
+// ListProjectConfigs returns the project configs of the ConfigSearchPath by filename
 func ListProjectConfigs(currentDirectory string) (map[string]ProjectConfig, error) {
-	var projectConfigs = map[string]ProjectConfig{}
-
-	if err := util.WalkDirIgnored(
-		"project_config", "",
-		func(path string, d fs.DirEntry) error {
-			if d.IsDir() {
-				return nil
-			}
-			if strings.HasSuffix(path, ".toml") {
-				content, err := os.ReadFile(path)
-				if err != nil {
-					return err
-				}
-				var config ProjectConfig
-				if err = toml.Unmarshal(content, &config); err != nil {
-					return err
-				}
-				config.RootPath = currentDirectory
-				projectConfigs[d.Name()] = config
-			}
-			return nil
-		}); err != nil {
-		return nil, err
-	}
-
-	return projectConfigs, nil
+	_, projectConfigs, err := loadProjectConfigs(currentDirectory, "")
+	return projectConfigs, err
 }
 
 func (pc *ProjectConfig) BuildPackageFiles() (map[string][]string, error) {
 	packageFileMap := map[string][]string{}
 	switch pc.ModuleMatch {
 	case "directory":
-		if err := util.WalkDirIgnored(
-			pc.RootPath,
-			filepath.Join(pc.RootPath, ".gitignore"),
-			func(path string, d fs.DirEntry) error {
-				if filepath.Dir(path) == pc.RootPath || path == pc.RootPath {
-					return nil
-				}
-				for _, filter := range pc.FileFilter {
-					if strings.HasSuffix(d.Name(), filter) {
-						relPath, err := filepath.Rel(pc.RootPath, path)
-						if err != nil {
-							return err
-						}
-						key := filepath.Dir(relPath)
-
-						if _, exists := packageFileMap[key]; !exists {
-							packageFileMap[key] = []string{}
-						}
-						packageFileMap[key] = append(packageFileMap[key], relPath)
-					}
-				}
-
-				return nil
-			}); err != nil {
+		if err := pc.walkFilterFiles(func(relPath string) error {
+			key := filepath.Dir(relPath)
+			// The project root files are not documented by the directory mode
+			if key != "." {
+				packageFileMap[key] = append(packageFileMap[key], relPath)
+			}
+			return nil
+		}); err != nil {
 			return nil, err
 		}
 
 	case "go_package":
-		if err := util.WalkDirIgnored(
-			pc.RootPath,
-			filepath.Join(pc.RootPath, ".gitignore"),
-			func(path string, d fs.DirEntry) error {
-				for _, filter := range pc.FileFilter {
-					if strings.HasSuffix(d.Name(), filter) {
-						fset := token.NewFileSet()
-						ast, err := parser.ParseFile(fset, path, nil, 0)
-						if err != nil {
-							return err
-						}
-						relPath, err := filepath.Rel(pc.RootPath, path)
-						if err != nil {
-							return err
-						}
-						key := fmt.Sprintf("%s:%s", filepath.Dir(relPath), ast.Name.Name)
-
-						if _, exists := packageFileMap[key]; !exists {
-							packageFileMap[key] = []string{}
-						}
-						packageFileMap[key] = append(packageFileMap[key], relPath)
-					}
-				}
-
-				return nil
-			}); err != nil {
+		pc.goImports = map[string][]string{}
+		if err := pc.walkFilterFiles(func(relPath string) error {
+			fset := token.NewFileSet()
+			ast, err := parser.ParseFile(fset, filepath.Join(pc.RootPath, relPath), nil, 0)
+			if err != nil {
+				return err
+			}
+			key := fmt.Sprintf("%s:%s", filepath.Dir(relPath), ast.Name.Name)
+			packageFileMap[key] = append(packageFileMap[key], relPath)
+			pc.goImports[relPath] = goFileImports(ast)
+			return nil
+		}); err != nil {
 			return nil, err
 		}
 
+	case "python_package":
+		return pc.buildPythonPackageFiles()
+
+	case "ts_workspace":
+		return pc.buildTSWorkspaceFiles()
+
+	case "rust_crate":
+		return pc.buildRustCrateFiles()
+
+	case "jvm_package":
+		return pc.buildJVMPackageFiles()
+
 	default:
 		return nil, errors.New(pc.ModuleMatch + " module match mode unimplemented")
 	}
@@ -180,6 +195,78 @@ func (pc *ProjectConfig) BuildPackageFiles() (map[string][]string, error) {
 	return packageFileMap, nil
 }
 
+// PackageDir returns the project root relative directory for the package README:
+// the common directory of the ts_workspace package files spanning the whole workspace package,
+// otherwise the deepest one of the package file directories, the sorted first one of the same depth,
+// e.g. the src/main one of the jvm_package files
+func (pc *ProjectConfig) PackageDir(files []string) string {
+	dirs := []string{}
+	for _, relPath := range files {
+		dirs = append(dirs, filepath.Dir(relPath))
+	}
+	if len(dirs) == 0 {
+		return "."
+	}
+	slices.Sort(dirs)
+
+	if pc.ModuleMatch == "ts_workspace" {
+		common := strings.Split(dirs[0], string(filepath.Separator))
+		for _, dir := range dirs[1:] {
+			parts := strings.Split(dir, string(filepath.Separator))
+			i := 0
+			for i < len(common) && i < len(parts) && common[i] == parts[i] {
+				i++
+			}
+			common = common[:i]
+		}
+		if len(common) == 0 {
+			return "."
+		}
+		return filepath.Join(common...)
+	}
+
+	deepest := dirs[0]
+	for _, dir := range dirs[1:] {
+		if pathDepth(dir) > pathDepth(deepest) {
+			deepest = dir
+		}
+	}
+	return deepest
+}
+
+// walkFilterFiles calls f with the project root relative paths of the files matching FileFilter
+// and not matching Exclude
+func (pc *ProjectConfig) walkFilterFiles(f func(relPath string) error) error {
+	exclude, err := ignore.CompileIgnoreLines(pc.Exclude...)
+	if err != nil {
+		return fmt.Errorf("compile exclude patterns: %w", err)
+	}
+	return util.WalkDirIgnored(
+		pc.RootPath,
+		filepath.Join(pc.RootPath, ".gitignore"),
+		func(path string, d fs.DirEntry) error {
+			relPath, err := filepath.Rel(pc.RootPath, path)
+			if err != nil {
+				return err
+			}
+			if relPath != "." && exclude.MatchesPath(filepath.ToSlash(relPath)) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			for _, filter := range pc.FileFilter {
+				if strings.HasSuffix(d.Name(), filter) {
+					return f(relPath)
+				}
+			}
+			return nil
+		})
+}
+
 func hasFilterFiles(workdir string, filters []string) (bool, error) {
 	found := false
 
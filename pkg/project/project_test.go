@@ -0,0 +1,717 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	projectconfig "github.com/JackBekket/reflexia/project_config"
+)
+
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range files {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func testBuildPackageFiles(
+	t *testing.T, moduleMatch string, fileFilter []string,
+	files map[string]string, expected map[string][]string,
+) {
+	t.Helper()
+	pc := ProjectConfig{
+		FileFilter:  fileFilter,
+		ModuleMatch: moduleMatch,
+		RootPath:    writeProject(t, files),
+	}
+	pkgFiles, err := pc.BuildPackageFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pkg := range pkgFiles {
+		slices.Sort(pkgFiles[pkg])
+	}
+	if !maps.EqualFunc(pkgFiles, expected, slices.Equal) {
+		t.Fatalf("unexpected package files\n got: %v\nwant: %v", pkgFiles, expected)
+	}
+}
+
+func TestBuildPythonPackageFiles(t *testing.T) {
+	t.Run("src layout", func(t *testing.T) {
+		testBuildPackageFiles(t, "python_package", []string{".py"}, map[string]string{
+			"setup.py":                  "",
+			"src/app/__init__.py":       "",
+			"src/app/main.py":           "",
+			"src/app/api/__init__.py":   "",
+			"src/app/api/routes.py":     "",
+			"src/ns/plugin/__init__.py": "",
+			"src/cli.py":                "",
+			"scripts/release.py":        "",
+			"tests/test_main.py":        "",
+			"tests/fixtures/data.py":    "",
+			"my-tool/__init__.py":       "",
+		}, map[string][]string{
+			".":              {"setup.py"},
+			"src":            {"src/cli.py"},
+			"app":            {"src/app/__init__.py", "src/app/main.py"},
+			"app.api":        {"src/app/api/__init__.py", "src/app/api/routes.py"},
+			"ns.plugin":      {"src/ns/plugin/__init__.py"},
+			"scripts":        {"scripts/release.py"},
+			"tests":          {"tests/test_main.py"},
+			"tests/fixtures": {"tests/fixtures/data.py"},
+			"my-tool":        {"my-tool/__init__.py"},
+		})
+	})
+
+	t.Run("namespace packages", func(t *testing.T) {
+		testBuildPackageFiles(t, "python_package", []string{".py"}, map[string]string{
+			"src/ns/util.py":               "",
+			"src/ns/plugin/sub/hooks.py":   "",
+			"plugins/base.py":              "",
+			"plugins/auth/__init__.py":     "",
+			"plugins/auth/backends/jwt.py": "",
+			"tests/__init__.py":            "",
+			"tests/fixtures/data.py":       "",
+			"scripts/release.py":           "",
+			"my-tool/sub/tool.py":          "",
+		}, map[string][]string{
+			"ns":                    {"src/ns/util.py"},
+			"ns.plugin.sub":         {"src/ns/plugin/sub/hooks.py"},
+			"plugins":               {"plugins/base.py"},
+			"plugins.auth":          {"plugins/auth/__init__.py"},
+			"plugins.auth.backends": {"plugins/auth/backends/jwt.py"},
+			"tests":                 {"tests/__init__.py"},
+			"tests.fixtures":        {"tests/fixtures/data.py"},
+			"scripts":               {"scripts/release.py"},
+			"my-tool/sub":           {"my-tool/sub/tool.py"},
+		})
+	})
+
+	t.Run("pyproject declared", func(t *testing.T) {
+		testBuildPackageFiles(t, "python_package", []string{".py"}, map[string]string{
+			"pyproject.toml": `
+[tool.setuptools.package-dir]
+mylib = "lib/mylib"
+
+[[tool.poetry.packages]]
+include = "tool"
+from = "python"
+`,
+			"lib/mylib/__init__.py":   "",
+			"lib/mylib/core/core.py":  "",
+			"python/tool/__init__.py": "",
+			"src/__init__.py":         "",
+			"src/module.py":           "",
+		}, map[string][]string{
+			"mylib":      {"lib/mylib/__init__.py"},
+			"mylib.core": {"lib/mylib/core/core.py"},
+			"tool":       {"python/tool/__init__.py"},
+			"src":        {"src/__init__.py", "src/module.py"},
+		})
+	})
+
+	t.Run("setuptools packages list", func(t *testing.T) {
+		testBuildPackageFiles(t, "python_package", []string{".py"}, map[string]string{
+			"pyproject.toml": `
+[project]
+name = "my-app"
+
+[tool.setuptools]
+packages = ["lib", "lib.core"]
+`,
+			"my_app/main.py":   "",
+			"lib/util.py":      "",
+			"lib/core/core.py": "",
+			"lib/data/load.py": "",
+		}, map[string][]string{
+			"my_app":   {"my_app/main.py"},
+			"lib":      {"lib/util.py"},
+			"lib.core": {"lib/core/core.py"},
+			"lib.data": {"lib/data/load.py"},
+		})
+	})
+}
+
+func TestBuildTSWorkspaceFiles(t *testing.T) {
+	t.Run("workspaces", func(t *testing.T) {
+		testBuildPackageFiles(t, "ts_workspace", []string{".ts", ".tsx"}, map[string]string{
+			"package.json":                  `{"name": "monorepo", "workspaces": {"packages": ["packages/*", "!packages/ignored"]}}`,
+			"pnpm-workspace.yaml":           "packages:\n  - 'apps/**'\n",
+			"tsconfig.json":                 "{\n  // solution config\n  \"references\": [{\"path\": \"./tools/build/tsconfig.json\"},],\n}\n",
+			"scripts/release.ts":            "",
+			"packages/core/package.json":    `{"name": "@acme/core"}`,
+			"packages/core/src/index.ts":    "",
+			"packages/core/src/util/fmt.ts": "",
+			"packages/ignored/package.json": `{"name": "@acme/ignored"}`,
+			"packages/ignored/index.ts":     "",
+			"apps/web/package.json":         `{"name": "web"}`,
+			"apps/web/src/App.tsx":          "",
+			"apps/web/node_modules/x/x.ts":  "",
+			"tools/build/tsconfig.json":     "{}",
+			"tools/build/build.ts":          "",
+		}, map[string][]string{
+			"monorepo":    {"packages/ignored/index.ts", "scripts/release.ts"},
+			"@acme/core":  {"packages/core/src/index.ts", "packages/core/src/util/fmt.ts"},
+			"web":         {"apps/web/src/App.tsx"},
+			"tools/build": {"tools/build/build.ts"},
+		})
+	})
+
+	t.Run("no workspaces", func(t *testing.T) {
+		testBuildPackageFiles(t, "ts_workspace", []string{".ts"}, map[string]string{
+			"package.json": `{"name": "app"}`,
+			"src/index.ts": "",
+			"src/lib/a.ts": "",
+		}, map[string][]string{
+			"src":     {"src/index.ts"},
+			"src/lib": {"src/lib/a.ts"},
+		})
+	})
+}
+
+func TestStripJSONComments(t *testing.T) {
+	content := "{\n  // comment\n  \"paths\": [\"a,]\", \"b // c\",], /* block */\n  \"x\": {\"y\": \",}\",},\n}\n"
+	var parsed map[string]any
+	if err := json.Unmarshal(stripJSONComments([]byte(content)), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"paths": []any{"a,]", "b // c"},
+		"x":     map[string]any{"y": ",}"},
+	}
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Fatalf("unexpected stripped JSON %v", parsed)
+	}
+}
+
+func TestBuildRustCrateFiles(t *testing.T) {
+	testBuildPackageFiles(t, "rust_crate", []string{".rs"}, map[string]string{
+		"Cargo.toml":                  "[workspace]\nmembers = [\"server\", \"tools/*\"]\n",
+		"server/Cargo.toml":           "[package]\nname = \"server\"\n",
+		"server/build.rs":             "",
+		"server/src/main.rs":          "",
+		"server/src/config.rs":        "",
+		"server/src/net.rs":           "",
+		"server/src/net/http.rs":      "",
+		"server/src/net/http/body.rs": "",
+		"server/tests/api.rs":         "",
+		"server/target/debug/gen.rs":  "",
+		"tools/gen/Cargo.toml":        "[package]\nname = \"gen\"\n",
+		"tools/gen/src/lib.rs":        "",
+		"scripts/check.rs":            "",
+	}, map[string][]string{
+		"server":            {"server/build.rs", "server/src/config.rs", "server/src/main.rs"},
+		"server::net":       {"server/src/net.rs"},
+		"server::net::http": {"server/src/net/http.rs", "server/src/net/http/body.rs"},
+		"server/tests":      {"server/tests/api.rs"},
+		"gen":               {"tools/gen/src/lib.rs"},
+		"scripts":           {"scripts/check.rs"},
+	})
+}
+
+func TestBuildJVMPackageFiles(t *testing.T) {
+	testBuildPackageFiles(t, "jvm_package", []string{".java", ".kt"}, map[string]string{
+		"pom.xml":                                  "",
+		"src/main/java/com/acme/App.java":          "// App\npackage com.acme;\n\nclass App {}\n",
+		"src/test/java/com/acme/AppTest.java":      "package com.acme;\n",
+		"src/main/kotlin/com/acme/util/Strings.kt": "@file:JvmName(\"Strings\")\npackage com.acme.util\n",
+		"api/src/main/java/com/acme/Api.java":      "package com.acme;\n",
+		"Script.java":                              "class Script {}\n",
+	}, map[string][]string{
+		".:com.acme":      {"src/main/java/com/acme/App.java", "src/test/java/com/acme/AppTest.java"},
+		".:com.acme.util": {"src/main/kotlin/com/acme/util/Strings.kt"},
+		"api:com.acme":    {"api/src/main/java/com/acme/Api.java"},
+		".:default":       {"Script.java"},
+	})
+}
+
+func TestFileImports(t *testing.T) {
+	for relPath, test := range map[string]struct {
+		content string
+		imports []string
+	}{
+		"main.go": {
+			"package main\n\nimport (\n\t\"fmt\"\n\tlog \"github.com/rs/zerolog/log\"\n)\n",
+			[]string{"fmt", "github.com/rs/zerolog/log"},
+		},
+		"app.py": {
+			"import os\nfrom app.models import User\nimport app.api, json as j,sys\n",
+			[]string{"app.api", "app.models", "json", "os", "sys"},
+		},
+		"index.ts": {
+			"import { a } from './a';\nimport './styles.css';\nconst b = require(\"b\");\n",
+			[]string{"./a", "./styles.css", "b"},
+		},
+		"lib.rs":    {"use std::io;\npub use crate::net::http;\n", []string{"crate::net::http", "std::io"}},
+		"Main.java": {"package app;\nimport java.util.List;\n", []string{"java.util.List"}},
+		"main.cpp":  {"#include <vector>\n#include \"util.h\"\n", []string{"util.h", "vector"}},
+		"README.md": {"import os", nil},
+	} {
+		if imports := FileImports(relPath, test.content); !slices.Equal(imports, test.imports) {
+			t.Errorf("%s: expected imports %v, got %v", relPath, test.imports, imports)
+		}
+	}
+}
+
+func TestBuildPackageGraph(t *testing.T) {
+	for name, test := range map[string]struct {
+		moduleMatch  string
+		fileFilter   []string
+		files        map[string]string
+		dependencies map[string][]string
+	}{
+		"go": {"go_package", []string{".go"}, map[string]string{
+			"go.mod":                    "module example.com/app\n",
+			"main.go":                   "package main\n\nimport (\n\t\"fmt\"\n\t\"example.com/app/util\"\n)\n",
+			"util/util.go":              "package util\n",
+			"util/util_test.go":         "package util_test\n\nimport \"example.com/app/util\"\n",
+			"internal/db/db.go":         "package db\n\nimport \"example.com/app\"\n",
+			"internal/db/db_helpers.go": "package db\n\nimport \"example.com/app/util\"\n",
+		}, map[string][]string{
+			".:main":         {"util:util"},
+			"util:util_test": {"util:util"},
+			"internal/db:db": {".:main", "util:util"},
+		}},
+		"python": {"python_package", []string{".py"}, map[string]string{
+			"src/app/__init__.py":     "from .api import routes\n",
+			"src/app/models.py":       "import os\n",
+			"src/app/api/__init__.py": "",
+			"src/app/api/routes.py":   "from ..models import User\nimport helpers\n",
+			"src/helpers.py":          "from app.models import User\n",
+		}, map[string][]string{
+			"app":     {"app.api"},
+			"app.api": {"app", "src"},
+			"src":     {"app"},
+		}},
+		"typescript": {"ts_workspace", []string{".ts"}, map[string]string{
+			"package.json":                  `{"name": "monorepo", "workspaces": ["packages/*"]}`,
+			"packages/core/package.json":    `{"name": "@acme/core"}`,
+			"packages/core/src/index.ts":    "export * from './util/fmt.js';\n",
+			"packages/core/src/util/fmt.ts": "",
+			"packages/web/package.json":     `{"name": "@acme/web"}`,
+			"packages/web/src/app.ts":       "import { fmt } from '@acme/core/util';\nimport '../../../scripts/env';\n",
+			"scripts/env/index.ts":          "",
+		}, map[string][]string{
+			"@acme/web": {"@acme/core", "monorepo"},
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			pc := ProjectConfig{
+				FileFilter:  test.fileFilter,
+				ModuleMatch: test.moduleMatch,
+				RootPath:    writeProject(t, test.files),
+			}
+			pkgFiles, err := pc.BuildPackageFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			graph, err := pc.BuildPackageGraph(pkgFiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.EqualFunc(graph.Dependencies, test.dependencies, slices.Equal) {
+				t.Fatalf("unexpected dependencies\n got: %v\nwant: %v", graph.Dependencies, test.dependencies)
+			}
+			for pkg, dependencies := range test.dependencies {
+				for _, dependency := range dependencies {
+					if !slices.Contains(graph.Dependents[dependency], pkg) {
+						t.Errorf("expected %s in the %s dependents %v", pkg, dependency, graph.Dependents[dependency])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPackageDir(t *testing.T) {
+	for _, test := range []struct {
+		moduleMatch string
+		files       []string
+		expected    string
+	}{
+		{"go_package", []string{"main.go"}, "."},
+		{"jvm_package", []string{"src/test/java/a/ATest.java", "src/main/java/a/A.java"}, "src/main/java/a"},
+		{"rust_crate", []string{"src/net.rs", "src/net/http.rs"}, "src/net"},
+		{"ts_workspace", []string{"packages/a/src/index.ts", "packages/a/test/a.test.ts"}, "packages/a"},
+		{"ts_workspace", []string{"scripts/a.ts", "index.ts"}, "."},
+	} {
+		pc := ProjectConfig{ModuleMatch: test.moduleMatch}
+		if dir := pc.PackageDir(test.files); dir != test.expected {
+			t.Errorf("%s %v package dir %s, expected %s", test.moduleMatch, test.files, dir, test.expected)
+		}
+	}
+}
+
+func TestConfigSearchPath(t *testing.T) {
+	workdir := writeProject(t, map[string]string{
+		".reflexia/project_config/go.toml": "extends = \"go.toml\"\nmodule_match = \"directory\"\n",
+		"custom/py.toml":                   "extends = \"py.toml\"\nmodule_match = \"directory\"\n",
+	})
+	systemConfigDir := SystemConfigDir
+	t.Cleanup(func() { SystemConfigDir = systemConfigDir })
+	SystemConfigDir = filepath.Join(workdir, "system")
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(workdir, "user"))
+	if err := os.MkdirAll(SystemConfigDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(SystemConfigDir, "kotlin.toml"), []byte("extends = \"jvm.toml\"\nfile_filter = [\".kt\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The broken overridden config does not fail the selected one
+	if err := os.WriteFile(filepath.Join(SystemConfigDir, "go.toml"), []byte("module_match = [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(workdir)
+
+	files, err := ListProjectConfigSources(workdir, filepath.Join(workdir, "custom/py.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := map[string]string{}
+	for _, file := range files {
+		if !file.Overridden {
+			used[file.Name] = file.Source
+		}
+	}
+	for name, source := range map[string]string{
+		"go.toml":     SourceRepository,
+		"py.toml":     SourceFile,
+		"kotlin.toml": SourceSystem,
+		"ts.toml":     SourceEmbedded,
+	} {
+		if used[name] != source {
+			t.Errorf("expected %s from %s source, got %s", name, source, used[name])
+		}
+	}
+
+	variants, err := GetProjectConfig(workdir, "go.toml", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config := variants["go.toml"]; config == nil || config.ModuleMatch != "directory" {
+		t.Fatalf("expected repository go.toml override, got %+v", config)
+	}
+	if _, err := GetProjectConfig(workdir, "missing.toml", false); err == nil {
+		t.Fatal("expected missing config error")
+	}
+}
+
+func TestProjectConfigInheritance(t *testing.T) {
+	workdir := writeProject(t, map[string]string{
+		".reflexia/project_config/go.toml": `extends = "go.toml"
+include = ["_todo.toml"]
+file_filter = [".go", ".tmpl"]
+
+[prompts.default]
+package = "repository package prompt"
+`,
+		".reflexia/project_config/_todo.toml": `[prompts.default]
+code = "list the TODO comments"
+`,
+		".reflexia/project_config/loop.toml":  "extends = \"_loop.toml\"\n",
+		".reflexia/project_config/_loop.toml": "extends = \"loop.toml\"\n",
+	})
+	systemConfigDir := SystemConfigDir
+	t.Cleanup(func() { SystemConfigDir = systemConfigDir })
+	SystemConfigDir = filepath.Join(workdir, "system")
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(workdir, "user"))
+	t.Chdir(workdir)
+
+	if _, err := GetProjectConfig(workdir, "loop.toml", false); err == nil {
+		t.Fatal("expected inheritance cycle error")
+	}
+	// The broken config which is not selected is skipped
+	if _, err := GetProjectConfig(workdir, "go.toml", false); err != nil {
+		t.Fatal(err)
+	}
+	if configs, err := ListProjectConfigs(workdir); err != nil {
+		t.Fatal(err)
+	} else if _, exists := configs["loop.toml"]; exists || configs["go.toml"].Name == "" {
+		t.Fatalf("expected broken loop.toml to be skipped, got %v", slices.Sorted(maps.Keys(configs)))
+	}
+	if err := os.Remove(filepath.Join(workdir, ".reflexia/project_config/_loop.toml")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetProjectConfig(workdir, "loop.toml", false); err == nil {
+		t.Fatal("expected missing extends error")
+	}
+	if err := os.Remove(filepath.Join(workdir, ".reflexia/project_config/loop.toml")); err != nil {
+		t.Fatal(err)
+	}
+
+	escaping := filepath.Join(workdir, ".reflexia/project_config/escape.toml")
+	for _, ref := range []string{"../../secret.toml", filepath.Join(workdir, "secret.toml"), "..\\secret.toml"} {
+		if err := os.WriteFile(escaping, []byte(fmt.Sprintf("extends = %q\n", ref)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := GetProjectConfig(workdir, "escape.toml", false); err == nil ||
+			!strings.Contains(err.Error(), "is not a path inside") {
+			t.Fatalf("expected %s reference to be rejected, got %v", ref, err)
+		}
+	}
+	if err := os.Remove(escaping); err != nil {
+		t.Fatal(err)
+	}
+
+	variants, err := GetProjectConfig(workdir, "go.toml", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := variants["go.toml"]
+	if !slices.Equal(config.FileFilter, []string{".go", ".tmpl"}) || config.ModuleMatch != "go_package" {
+		t.Errorf("expected overridden file filter and inherited module match, got %+v", config)
+	}
+	prompts := config.Prompts["default"]
+	if prompts.PackagePrompt != "repository package prompt" || prompts.CodePrompt != "list the TODO comments" {
+		t.Errorf("expected overridden package and code prompts, got %+v", prompts)
+	}
+	if prompts.ProjectPrompt == "" || config.Prompts["qwen3.*"].CodePrompt == "" || config.StopWords == nil {
+		t.Errorf("expected inherited embedded prompts, got %+v", config.Prompts)
+	}
+	if !slices.Equal(config.Inherits, []string{
+		"_base.toml",
+		"go.toml",
+		filepath.Join(workdir, ".reflexia/project_config/_todo.toml"),
+	}) {
+		t.Errorf("unexpected inherits %v", config.Inherits)
+	}
+}
+
+func TestValidateProjectConfigContent(t *testing.T) {
+	workdir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(workdir, "user"))
+	t.Chdir(workdir)
+
+	problems, err := ValidateProjectConfigContent(workdir, "custom.toml", []byte(`extends = "go.toml"
+module_match = "go_packages"
+file_filters = [".go"]
+
+[chunking]
+max_tokens = "many"
+
+[prompts."qwen3.(*"]
+code = "{{.Content"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, problem := range problems {
+		got = append(got, problem.Error())
+	}
+	expected := []string{
+		"custom.toml:3:1: file_filters: unknown key",
+		"custom.toml:6:1: chunking.max_tokens: expected an integer, got a string",
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected type problems %q, got %q", expected, got)
+	}
+
+	problems, err = ValidateProjectConfigContent(workdir, "custom.toml", []byte(`extends = "go.toml"
+module_match = "go_packages"
+
+[prompts."qwen3.(*"]
+code = "{{.Content"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = []string{}
+	for _, problem := range problems {
+		got = append(got, problem.Error())
+	}
+	expected = []string{
+		`custom.toml:2:1: module_match: unknown module match mode "go_packages", expected one of ` +
+			strings.Join(ModuleMatchModes, ", "),
+		`custom.toml:4:10: prompts."qwen3.(*": invalid model regexp: error parsing regexp: missing argument to repetition operator: ` + "`*`",
+		`custom.toml:4:10: prompts."qwen3.(*".package: prompt is required`,
+		`custom.toml:5:1: prompts."qwen3.(*".code: invalid prompt template: template: prompts."qwen3.(*".code:1: unclosed action`,
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected value problems %q, got %q", expected, got)
+	}
+
+	problems, err = ValidateProjectConfigContent(workdir, "custom.toml", []byte("extends = \"missing.toml\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].Line != 1 || problems[0].Key != "extends" {
+		t.Errorf("expected missing extends problem, got %v", problems)
+	}
+
+	problems, err = ValidateProjectConfigs(workdir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("expected valid embedded configs, got %v", problems)
+	}
+}
+
+func TestProjectConfigSchema(t *testing.T) {
+	type schemaObject struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	var schema struct {
+		schemaObject
+		Definitions map[string]schemaObject `json:"definitions"`
+	}
+	if err := json.Unmarshal(projectconfig.Schema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	var moduleMatch struct {
+		Enum []string `json:"enum"`
+	}
+	if err := json.Unmarshal(schema.Properties["module_match"], &moduleMatch); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(moduleMatch.Enum, ModuleMatchModes) {
+		t.Errorf("expected module_match enum %v, got %v", ModuleMatchModes, moduleMatch.Enum)
+	}
+
+	var chunking schemaObject
+	if err := json.Unmarshal(schema.Properties["chunking"], &chunking); err != nil {
+		t.Fatal(err)
+	}
+	for _, object := range []struct {
+		properties map[string]json.RawMessage
+		v          any
+	}{
+		{schema.Properties, ProjectConfig{}},
+		{chunking.Properties, ProjectConfigChunking{}},
+		{schema.Definitions["prompts"].Properties, ProjectConfigPrompts{}},
+	} {
+		typ := reflect.TypeOf(object.v)
+		for i := range typ.NumField() {
+			if key := tomlKey(typ.Field(i)); key != "-" {
+				if _, exists := object.properties[key]; !exists {
+					t.Errorf("%s key %s is missing in the schema", typ.Name(), key)
+				}
+			}
+		}
+	}
+}
+
+func TestScoreChooser(t *testing.T) {
+	workdir := writeProject(t, map[string]string{
+		"go.mod":                      "module example.com/app\n",
+		"main.go":                     "package main\n",
+		"util/util.go":                "package util\n",
+		"util/cgo.go":                 "package util\n",
+		"third_party/lib/include/a.h": "int a();\n",
+	})
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(workdir, "user"))
+	t.Chdir(workdir)
+
+	variants, err := GetProjectConfig(workdir, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := variants["cpp.toml"]; !exists {
+		t.Fatalf("expected cpp.toml candidate, got %v", slices.Sorted(maps.Keys(variants)))
+	}
+	if _, err := ScoreChooser(nil); !errors.Is(err, ErrNoProjectConfig) {
+		t.Fatalf("expected no project config error, got %v", err)
+	}
+	if err := NoProjectConfigError(workdir); !strings.HasSuffix(err.Error(), "available languages: cpp, go, jvm, py, rust, ts") {
+		t.Fatalf("unexpected available languages %v", err)
+	}
+	config, err := ScoreChooser(variants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Name != "go.toml" {
+		t.Fatalf("expected go.toml chosen, got %s: %v", config.Name, config.Ranking)
+	}
+	if len(config.Ranking) != len(variants) {
+		t.Fatalf("expected ranking of every candidate, got %v", config.Ranking)
+	}
+	best := config.Ranking[0]
+	if best.MatchingFiles != 3 || best.TotalFiles != 4 || best.RootMarker != "go.mod" {
+		t.Errorf("unexpected go.toml score %v", best)
+	}
+}
+
+func TestApplyRepositoryConfig(t *testing.T) {
+	workdir := writeProject(t, map[string]string{
+		"go.mod":             "module example.com/app\n",
+		"main.go":            "package main\n",
+		"util/util.go":       "package util\n",
+		"gen/api/api.gen.go": "package api\n",
+		RepositoryConfigFilename: `module_match = "directory"
+exclude = ["gen/"]
+
+[output]
+readme = "DOCS.md"
+
+[prompts.default]
+package = "repository package prompt"
+
+[[path_prompts]]
+paths = ["util/"]
+prompts = { code = "util code prompt" }
+`,
+	})
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(workdir, "user"))
+	t.Chdir(workdir)
+
+	variants, err := GetProjectConfig(workdir, "go.toml", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := variants["go.toml"]
+	repositoryConfig, err := ReadRepositoryConfig(workdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.ApplyRepositoryConfig(repositoryConfig); err != nil {
+		t.Fatal(err)
+	}
+	if config.ModuleMatch != "directory" || config.Output.ReadmeFilename() != "DOCS.md" ||
+		config.Output.FileSummaryFilename() != "FILES.md" || config.RootPath != workdir {
+		t.Errorf("unexpected merged config %+v", config)
+	}
+	prompts := config.Prompts["default"]
+	if prompts.PackagePrompt != "repository package prompt" || prompts.CodePrompt == "" {
+		t.Errorf("expected overridden package prompt and inherited code prompt, got %+v", prompts)
+	}
+	if len(config.PathPrompts) != 1 || config.PathPrompts[0].Prompts.CodePrompt != "util code prompt" {
+		t.Errorf("unexpected path prompts %+v", config.PathPrompts)
+	}
+	pkgFiles, err := config.BuildPackageFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(slices.Sorted(maps.Keys(pkgFiles)), []string{"util"}) {
+		t.Errorf("expected excluded gen directory, got %v", pkgFiles)
+	}
+
+	if err := os.WriteFile(filepath.Join(workdir, RepositoryConfigFilename), []byte("\nmodule_match = \"module\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	repositoryConfig, err = ReadRepositoryConfig(workdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = variants["go.toml"].ApplyRepositoryConfig(repositoryConfig)
+	var problem ValidationError
+	if !errors.As(err, &problem) || problem.Line != 2 || problem.Key != "module_match" {
+		t.Errorf("expected module_match problem at line 2, got %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// pythonSourceRoot is the directory importable as the dotted prefix package,
+// the empty prefix means the directory is on the python path
+type pythonSourceRoot struct {
+	dir    string
+	prefix string
+}
+
+type pyproject struct {
+	Project struct {
+		Name string `toml:"name"`
+	} `toml:"project"`
+	Tool struct {
+		Setuptools struct {
+			PackageDir map[string]string `toml:"package-dir"`
+			// Packages is either a list of the package names or the find table
+			Packages any `toml:"packages"`
+		} `toml:"setuptools"`
+		Poetry struct {
+			Packages []struct {
+				Include string `toml:"include"`
+				From    string `toml:"from"`
+			} `toml:"packages"`
+		} `toml:"poetry"`
+		Hatch struct {
+			Build struct {
+				Targets struct {
+					Wheel struct {
+						Packages []string `toml:"packages"`
+					} `toml:"wheel"`
+				} `toml:"targets"`
+			} `toml:"build"`
+		} `toml:"hatch"`
+	} `toml:"tool"`
+}
+
+// buildPythonPackageFiles groups the files by the importable package keyed by the dotted module path.
+// Files are resolved against the deepest source root containing them: the project root,
+// the src directory unless it is a package itself, and the pyproject.toml declared package directories.
+// A directory is a regular package if it has __init__.py or is declared in pyproject.toml,
+// and a PEP 420 namespace package if it is below a source root other than the project root,
+// inside a regular package or contains one.
+// Files of the other directories, such as the scripts and test fixtures, and top-level modules
+// of a source root are grouped under the directory path key, "." for the project root
+func (pc *ProjectConfig) buildPythonPackageFiles() (map[string][]string, error) {
+	roots, declared, err := pythonSourceRoots(pc.RootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The namespace packages of the project root depend on the other directories,
+	// so the keys are resolved after the walk
+	dirs := map[string]pythonDir{}
+	dirOrder := []string{}
+	dirFiles := map[string][]string{}
+	if err := pc.walkFilterFiles(func(relPath string) error {
+		dir := filepath.Dir(relPath)
+		if _, ok := dirs[dir]; !ok {
+			d, err := newPythonDir(pc.RootPath, roots, declared, dir)
+			if err != nil {
+				return err
+			}
+			dirs[dir] = d
+			dirOrder = append(dirOrder, dir)
+		}
+		dirFiles[dir] = append(dirFiles[dir], relPath)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	packageFileMap := map[string][]string{}
+	for _, dir := range dirOrder {
+		key := dirs[dir].key(dir, dirs)
+		packageFileMap[key] = append(packageFileMap[key], dirFiles[dir]...)
+	}
+	return packageFileMap, nil
+}
+
+var pythonIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
+// pythonDir is a directory resolved against its source root
+type pythonDir struct {
+	root pythonSourceRoot
+	// module is the dotted module path, empty for the source root itself
+	// or if a path segment is not an identifier
+	module string
+	// regular is set for the project root directories with __init__.py or declared in pyproject.toml,
+	// and for the directories inside them
+	regular bool
+}
+
+func newPythonDir(rootPath string, roots []pythonSourceRoot, declared map[string]bool, dir string) (pythonDir, error) {
+	d := pythonDir{root: pythonSourceRoot{dir: "."}}
+	for _, r := range roots {
+		if r.dir == "." || dir == r.dir || strings.HasPrefix(dir, r.dir+string(filepath.Separator)) {
+			d.root = r
+			break
+		}
+	}
+
+	modulePath := []string{}
+	if d.root.prefix != "" {
+		modulePath = append(modulePath, d.root.prefix)
+	}
+	rel, err := filepath.Rel(d.root.dir, dir)
+	if err != nil {
+		return d, err
+	}
+	if rel != "." {
+		modulePath = append(modulePath, strings.Split(filepath.ToSlash(rel), "/")...)
+	}
+	for _, name := range strings.Split(strings.Join(modulePath, "."), ".") {
+		if !pythonIdentifierRegexp.MatchString(name) {
+			return d, nil
+		}
+	}
+	d.module = strings.Join(modulePath, ".")
+
+	// The directories inside a regular package are importable as its subpackages
+	if d.root.dir == "." && d.root.prefix == "" {
+		for path := dir; path != "."; path = filepath.Dir(path) {
+			if declared[strings.ReplaceAll(filepath.ToSlash(path), "/", ".")] ||
+				isFile(filepath.Join(rootPath, path, "__init__.py")) {
+				d.regular = true
+				break
+			}
+		}
+	}
+	return d, nil
+}
+
+// key returns the dotted module path of the package directory, or the directory path otherwise
+func (d pythonDir) key(dir string, dirs map[string]pythonDir) string {
+	if d.module == "" {
+		return dir
+	}
+	if d.regular || d.root.dir != "." || d.root.prefix != "" {
+		return d.module
+	}
+	// A namespace package of the project root is recognized by a regular package inside it
+	for other, o := range dirs {
+		if o.regular && strings.HasPrefix(other, dir+string(filepath.Separator)) {
+			return d.module
+		}
+	}
+	return dir
+}
+
+// pythonSourceRoots returns the existing source roots sorted from the deepest one
+// and the dotted module paths of the packages declared in pyproject.toml
+func pythonSourceRoots(rootPath string) ([]pythonSourceRoot, map[string]bool, error) {
+	roots := []pythonSourceRoot{{dir: "."}}
+	declared := map[string]bool{}
+	if isDir(filepath.Join(rootPath, "src")) && !isFile(filepath.Join(rootPath, "src", "__init__.py")) {
+		roots = append(roots, pythonSourceRoot{dir: "src"})
+	}
+
+	content, err := os.ReadFile(filepath.Join(rootPath, "pyproject.toml"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+	if err == nil {
+		var project pyproject
+		if err := toml.Unmarshal(content, &project); err != nil {
+			return nil, nil, fmt.Errorf("parse pyproject.toml: %w", err)
+		}
+		// The build backends default to the package named after the project
+		if project.Project.Name != "" {
+			declared[strings.ToLower(strings.NewReplacer("-", "_", ".", "_").Replace(project.Project.Name))] = true
+		}
+		setuptools := project.Tool.Setuptools
+		for pkg, dir := range setuptools.PackageDir {
+			roots = append(roots, pythonSourceRoot{dir: dir, prefix: pkg})
+			if pkg != "" {
+				declared[pkg] = true
+			}
+		}
+		switch packages := setuptools.Packages.(type) {
+		case []any:
+			for _, pkg := range packages {
+				if pkg, ok := pkg.(string); ok {
+					declared[pkg] = true
+				}
+			}
+		case map[string]any:
+			if find, ok := packages["find"].(map[string]any); ok {
+				where, _ := find["where"].([]any)
+				for _, dir := range where {
+					if dir, ok := dir.(string); ok {
+						roots = append(roots, pythonSourceRoot{dir: dir})
+					}
+				}
+			}
+		}
+		for _, pkg := range project.Tool.Poetry.Packages {
+			if pkg.From != "" {
+				roots = append(roots, pythonSourceRoot{dir: pkg.From})
+			}
+			declared[strings.ReplaceAll(strings.Trim(pkg.Include, "/"), "/", ".")] = true
+		}
+		for _, pkg := range project.Tool.Hatch.Build.Targets.Wheel.Packages {
+			roots = append(roots, pythonSourceRoot{dir: filepath.Dir(pkg)})
+			declared[filepath.Base(pkg)] = true
+		}
+	}
+
+	existing := []pythonSourceRoot{}
+	for _, root := range roots {
+		root.dir = filepath.Clean(filepath.FromSlash(root.dir))
+		if !isDir(filepath.Join(rootPath, root.dir)) || slices.Contains(existing, root) {
+			continue
+		}
+		existing = append(existing, root)
+	}
+	slices.SortStableFunc(existing, func(a, b pythonSourceRoot) int {
+		return pathDepth(b.dir) - pathDepth(a.dir)
+	})
+	return existing, declared, nil
+}
+
+func pathDepth(path string) int {
+	if path == "." {
+		return 0
+	}
+	return strings.Count(path, string(filepath.Separator)) + 1
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
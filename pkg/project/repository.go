@@ -0,0 +1,75 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// RepositoryConfigFilename is the optional config of the documented repository root,
+// merged on top of the chosen project config
+const RepositoryConfigFilename = ".reflexia.toml"
+
+// RepositoryConfig is the .reflexia.toml document, it has the same keys as the project config
+// except extends and include
+type RepositoryConfig struct {
+	Path string
+	doc  configDoc
+}
+
+// ReadRepositoryConfig reads the .reflexia.toml of the workdir, nil if it is absent
+func ReadRepositoryConfig(workdir string) (*RepositoryConfig, error) {
+	path := filepath.Join(workdir, RepositoryConfigFilename)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	doc, err := parseConfigDoc(ConfigFile{Name: RepositoryConfigFilename, Source: SourceRepository, Path: path}, content)
+	if err != nil {
+		return nil, err
+	}
+	return &RepositoryConfig{Path: path, doc: doc}, nil
+}
+
+// ApplyRepositoryConfig merges the repository config on top of the project config like an including config,
+// except that the exclude patterns are added to the project config ones.
+// The merged config is validated with the problems positioned in the repository config
+func (pc *ProjectConfig) ApplyRepositoryConfig(rc *RepositoryConfig) error {
+	content, err := toml.Marshal(pc)
+	if err != nil {
+		return fmt.Errorf("encode project config %s: %w", pc.Name, err)
+	}
+	base := map[string]any{}
+	if err := toml.Unmarshal(content, &base); err != nil {
+		return fmt.Errorf("parse project config %s: %w", pc.Name, err)
+	}
+
+	merged := mergedDoc{values: mergeConfigDocs(base, rc.doc.doc), origins: map[string]ValidationError{}}
+	for key := range rc.doc.positions {
+		merged.origins[key] = rc.doc.problem(key, "")
+	}
+	config, problems := validateConfigDoc(rc.doc.file, merged)
+	if len(problems) > 0 {
+		return joinProblems(problems)
+	}
+
+	if _, exists := rc.doc.doc["exclude"]; exists {
+		config.Exclude = append(slices.Clone(pc.Exclude), config.Exclude...)
+	}
+	config.RootPath = pc.RootPath
+	config.Name = pc.Name
+	config.Source = pc.Source
+	config.Path = pc.Path
+	config.Inherits = pc.Inherits
+	config.Ranking = pc.Ranking
+	config.RepositoryConfig = rc.Path
+	*pc = config
+	return nil
+}
@@ -0,0 +1,113 @@
+package project
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/JackBekket/reflexia/internal/util"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// rustCrate is the project root relative directory of the Cargo.toml package
+type rustCrate struct {
+	dir  string
+	name string
+}
+
+// buildRustCrateFiles groups the files by the Cargo crate module tree.
+// Files of the crate src directory are keyed by the module path, e.g. "crate::net::http",
+// where the foo.rs module file belongs to the foo directory module if it exists.
+// Crate root files and build.rs are keyed by the crate name,
+// the other crate directories such as tests, benches and examples by "crate/dir",
+// files outside of the crates by the directory path
+func (pc *ProjectConfig) buildRustCrateFiles() (map[string][]string, error) {
+	crates, err := rustCrates(pc.RootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	packageFileMap := map[string][]string{}
+	if err := pc.walkFilterFiles(func(relPath string) error {
+		key := filepath.ToSlash(filepath.Dir(relPath))
+		crate, inCrate := rustCrate{}, ""
+		for _, c := range crates {
+			if c.dir == "." || strings.HasPrefix(relPath, c.dir+string(filepath.Separator)) {
+				crate = c
+				inCrate = strings.TrimPrefix(relPath, c.dir+string(filepath.Separator))
+				break
+			}
+		}
+		if crate.name != "" {
+			parts := strings.Split(filepath.ToSlash(inCrate), "/")
+			switch {
+			case parts[0] == "target":
+				return nil
+			case len(parts) == 1:
+				key = crate.name
+			case parts[0] == "src":
+				modules := []string{crate.name}
+				modules = append(modules, parts[1:len(parts)-1]...)
+				module := strings.TrimSuffix(parts[len(parts)-1], ".rs")
+				if isDir(filepath.Join(pc.RootPath, filepath.Dir(relPath), module)) {
+					modules = append(modules, module)
+				}
+				key = strings.Join(modules, "::")
+			default:
+				key = crate.name + "/" + strings.Join(parts[:len(parts)-1], "/")
+			}
+		}
+		packageFileMap[key] = append(packageFileMap[key], relPath)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return packageFileMap, nil
+}
+
+// rustCrates returns the crates sorted from the deepest directory
+func rustCrates(rootPath string) ([]rustCrate, error) {
+	crates := []rustCrate{}
+	if err := util.WalkDirIgnored(
+		rootPath,
+		filepath.Join(rootPath, ".gitignore"),
+		func(path string, d fs.DirEntry) error {
+			if d.IsDir() && d.Name() == "target" && isFile(filepath.Join(filepath.Dir(path), "Cargo.toml")) {
+				return filepath.SkipDir
+			}
+			if d.IsDir() || d.Name() != "Cargo.toml" {
+				return nil
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			var manifest struct {
+				Package struct {
+					Name string `toml:"name"`
+				} `toml:"package"`
+			}
+			if err := toml.Unmarshal(content, &manifest); err != nil {
+				return fmt.Errorf("parse %s: %w", path, err)
+			}
+			// Virtual workspace manifests have no package
+			if manifest.Package.Name == "" {
+				return nil
+			}
+			dir, err := filepath.Rel(rootPath, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			crates = append(crates, rustCrate{dir: dir, name: manifest.Package.Name})
+			return nil
+		}); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(crates, func(a, b rustCrate) int {
+		return pathDepth(b.dir) - pathDepth(a.dir)
+	})
+	return crates, nil
+}
@@ -0,0 +1,151 @@
+package project
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/JackBekket/reflexia/internal/util"
+)
+
+// Weights of the ConfigScore parts
+const (
+	fileShareWeight  = 0.6
+	rootMarkerWeight = 0.3
+	fileDepthWeight  = 0.1
+)
+
+// ConfigScore explains the rank of the project config candidate
+type ConfigScore struct {
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+	// MatchingFiles is the number of files matching the config file_filter,
+	// TotalFiles is the number of files matching any of the candidates filters
+	MatchingFiles int `json:"matching_files"`
+	TotalFiles    int `json:"total_files"`
+	// RootMarker is the shallowest project_root_filter file found, empty if there is none
+	RootMarker string `json:"root_marker,omitempty"`
+	// AverageDepth is the average directory depth of the matching files
+	AverageDepth float64 `json:"average_depth"`
+}
+
+func (s ConfigScore) String() string {
+	marker := "no root marker"
+	if s.RootMarker != "" {
+		marker = "root marker " + s.RootMarker
+	}
+	share := 0.0
+	if s.TotalFiles > 0 {
+		share = float64(s.MatchingFiles) / float64(s.TotalFiles)
+	}
+	return fmt.Sprintf("%s: score %.2f (%d of %d source files %.0f%%, %s, average file depth %.1f)",
+		s.Name, s.Score, s.MatchingFiles, s.TotalFiles, share*100, marker, s.AverageDepth,
+	)
+}
+
+// RankProjectConfigs scores the project config candidates of the same root directory
+// by the proportion of the source files matching the config file filter,
+// the root marker strength, where the marker in the root directory is the strongest
+// and the one of a subdirectory such as src/go.mod is weaker,
+// and the depth of the matching files, so the configs of the few deeply nested files,
+// e.g. vendored .h files in a Go repository, rank lower.
+// The scores are sorted from the best one, ties are broken by the config name
+func RankProjectConfigs(projectConfigVariants map[string]*ProjectConfig) ([]ConfigScore, error) {
+	scores := make([]ConfigScore, 0, len(projectConfigVariants))
+	depths := map[string]int{}
+	for name := range projectConfigVariants {
+		scores = append(scores, ConfigScore{Name: name})
+	}
+	slices.SortFunc(scores, func(a, b ConfigScore) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	if len(scores) == 0 {
+		return scores, nil
+	}
+
+	rootPath := projectConfigVariants[scores[0].Name].RootPath
+	total := 0
+	if err := util.WalkDirIgnored(
+		rootPath,
+		filepath.Join(rootPath, ".gitignore"),
+		func(path string, d fs.DirEntry) error {
+			if d.IsDir() {
+				if d.Name() == "node_modules" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			relPath, err := filepath.Rel(rootPath, path)
+			if err != nil {
+				return err
+			}
+			matched := false
+			for i, score := range scores {
+				for _, filter := range projectConfigVariants[score.Name].FileFilter {
+					if strings.HasSuffix(d.Name(), filter) {
+						scores[i].MatchingFiles++
+						depths[score.Name] += pathDepth(filepath.Dir(relPath))
+						matched = true
+						break
+					}
+				}
+			}
+			if matched {
+				total++
+			}
+			return nil
+		}); err != nil {
+		return nil, err
+	}
+
+	for i, score := range scores {
+		config := projectConfigVariants[score.Name]
+		scores[i].TotalFiles = total
+		markerStrength := 0.0
+		for _, filter := range config.ProjectRootFilter {
+			if _, err := os.Stat(filepath.Join(rootPath, filter)); err == nil {
+				strength := 1 / float64(1+pathDepth(filepath.Dir(filter)))
+				if strength > markerStrength {
+					markerStrength = strength
+					scores[i].RootMarker = filter
+				}
+			}
+		}
+
+		fileShare, depthScore := 0.0, 0.0
+		if score.MatchingFiles > 0 {
+			fileShare = float64(score.MatchingFiles) / float64(total)
+			scores[i].AverageDepth = float64(depths[score.Name]) / float64(score.MatchingFiles)
+			depthScore = 1 / (1 + scores[i].AverageDepth)
+		}
+		scores[i].Score = fileShareWeight*fileShare + rootMarkerWeight*markerStrength + fileDepthWeight*depthScore
+	}
+	slices.SortStableFunc(scores, func(a, b ConfigScore) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return scores, nil
+}
+
+// ScoreChooser picks the best ranked project config, see RankProjectConfigs.
+// The ranking is set to the Ranking of the chosen config if there are several candidates
+func ScoreChooser(projectConfigVariants map[string]*ProjectConfig) (*ProjectConfig, error) {
+	switch len(projectConfigVariants) {
+	case 0:
+		return nil, ErrNoProjectConfig
+	case 1:
+		for _, pc := range projectConfigVariants {
+			return pc, nil
+		}
+	}
+	scores, err := RankProjectConfigs(projectConfigVariants)
+	if err != nil {
+		return nil, fmt.Errorf("rank project configs: %w", err)
+	}
+	pc := projectConfigVariants[scores[0].Name]
+	pc.Ranking = scores
+	return pc, nil
+}
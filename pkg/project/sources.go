@@ -0,0 +1,256 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	projectconfig "github.com/JackBekket/reflexia/project_config"
+	"github.com/rs/zerolog/log"
+)
+
+// Project config sources in the order of increasing precedence,
+// a config of the later source overrides the same named config of the earlier one
+const (
+	SourceEmbedded   = "embedded"
+	SourceSystem     = "system"
+	SourceUser       = "user"
+	SourceWorkdir    = "cwd"
+	SourceRepository = "repository"
+	SourceFile       = "file"
+)
+
+// SystemConfigDir is the system wide project config directory
+var SystemConfigDir = "/etc/reflexia/project_config"
+
+// ConfigSource is the directory of the project config toml files
+type ConfigSource struct {
+	Name string
+	// Dir is the directory path, "." for the embedded configs
+	Dir string
+	FS  fs.FS
+}
+
+// ConfigFile describes where the project config is loaded from
+type ConfigFile struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	// Overridden is set if the same named config of a later source is used instead
+	Overridden bool `json:"overridden,omitempty"`
+	// Partial is set for the _ prefixed files, which are only the bases for the extends and include keys
+	Partial bool `json:"partial,omitempty"`
+}
+
+// ConfigSearchPath returns the project config sources in the order of increasing precedence:
+// the configs embedded into the binary, SystemConfigDir, the user config dir reflexia/project_config,
+// the project_config directory of the process working directory
+// and the .reflexia/project_config directory of the documented repository
+func ConfigSearchPath(currentDirectory string) []ConfigSource {
+	sources := []ConfigSource{
+		{Name: SourceEmbedded, Dir: ".", FS: projectconfig.FS},
+		{Name: SourceSystem, Dir: SystemConfigDir},
+	}
+	if userConfigDir, err := os.UserConfigDir(); err == nil {
+		sources = append(sources, ConfigSource{
+			Name: SourceUser,
+			Dir:  filepath.Join(userConfigDir, "reflexia", "project_config"),
+		})
+	}
+	sources = append(sources, ConfigSource{Name: SourceWorkdir, Dir: "project_config"})
+	if currentDirectory != "" {
+		sources = append(sources, ConfigSource{
+			Name: SourceRepository,
+			Dir:  filepath.Join(currentDirectory, ".reflexia", "project_config"),
+		})
+	}
+	for i, source := range sources {
+		if source.FS == nil {
+			sources[i].FS = os.DirFS(source.Dir)
+		}
+	}
+	return sources
+}
+
+// ListProjectConfigSources returns every project config file found in the search path
+// and the explicit withConfigFile path if it is set, marking the overridden ones
+func ListProjectConfigSources(currentDirectory, withConfigFile string) ([]ConfigFile, error) {
+	files, _, err := loadProjectConfigs(currentDirectory, withConfigFile)
+	return files, err
+}
+
+// loadProjectConfigs reads the project configs in the search path order,
+// the explicit withConfigFile path is loaded last.
+// Every config is resolved and validated even if it is overridden, so the broken ones are reported.
+// Only the problems of the withConfigFile selected config fail the load,
+// the other broken configs are skipped with a warning
+func loadProjectConfigs(
+	currentDirectory, withConfigFile string,
+) ([]ConfigFile, map[string]ProjectConfig, error) {
+	docs, problems, err := readConfigDocs(currentDirectory, withConfigFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, projectConfigs, buildProblems := buildProjectConfigs(currentDirectory, docs)
+	problems = append(problems, buildProblems...)
+
+	// The config is used if no config of the same name has a higher precedence source
+	loaded := slices.Clone(files)
+	for _, problem := range problems {
+		loaded = append(loaded, problem.file)
+	}
+	used := func(file ConfigFile) bool {
+		return !slices.ContainsFunc(loaded, func(other ConfigFile) bool {
+			return other.Name == file.Name && sourcePrecedence(other.Source) > sourcePrecedence(file.Source)
+		})
+	}
+
+	selected := []ValidationError{}
+	for _, problem := range problems {
+		if !used(problem.file) {
+			log.Warn().Err(problem).Msgf("overridden %s project config %s", problem.file.Source, problem.file.Path)
+			continue
+		}
+		// The overridden config of the same name is not used in place of the broken one
+		delete(projectConfigs, problem.file.Name)
+		if withConfigFile != "" && problem.file.Name == filepath.Base(withConfigFile) {
+			selected = append(selected, problem)
+			continue
+		}
+		log.Warn().Err(problem).Msgf("skip %s project config %s", problem.file.Source, problem.file.Path)
+	}
+	if len(selected) > 0 {
+		return nil, nil, joinProblems(selected)
+	}
+	return files, projectConfigs, nil
+}
+
+// sourcePrecedence returns the index of the source in the order of increasing precedence
+func sourcePrecedence(source string) int {
+	return slices.Index([]string{
+		SourceEmbedded, SourceSystem, SourceUser, SourceWorkdir, SourceRepository, SourceFile,
+	}, source)
+}
+
+// readConfigDocs reads the toml documents of the search path and the withConfigFile path,
+// the syntax errors are returned as the problems
+func readConfigDocs(currentDirectory, withConfigFile string) ([]configDoc, []ValidationError, error) {
+	docs := []configDoc{}
+	problems := []ValidationError{}
+	add := func(file ConfigFile, content []byte) {
+		doc, err := parseConfigDoc(file, content)
+		var problem ValidationError
+		if errors.As(err, &problem) {
+			problem.file = file
+			problems = append(problems, problem)
+			return
+		}
+		docs = append(docs, doc)
+	}
+
+	for _, source := range ConfigSearchPath(currentDirectory) {
+		if err := fs.WalkDir(source.FS, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == "." && errors.Is(err, fs.ErrNotExist) {
+					return fs.SkipAll
+				}
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".toml") {
+				return nil
+			}
+			content, err := fs.ReadFile(source.FS, path)
+			if err != nil {
+				return err
+			}
+			add(ConfigFile{
+				Name:   d.Name(),
+				Source: source.Name,
+				Path:   filepath.Join(source.Dir, filepath.FromSlash(path)),
+			}, content)
+			return nil
+		}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if info, err := os.Stat(withConfigFile); err == nil && !info.IsDir() {
+		content, err := os.ReadFile(withConfigFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		add(ConfigFile{
+			Name:   filepath.Base(withConfigFile),
+			Source: SourceFile,
+			Path:   withConfigFile,
+		}, content)
+	}
+	return docs, problems, nil
+}
+
+// buildProjectConfigs resolves the extends and include references of the documents,
+// validates and decodes the merged project configs
+func buildProjectConfigs(
+	currentDirectory string, docs []configDoc,
+) ([]ConfigFile, map[string]ProjectConfig, []ValidationError) {
+	files := []ConfigFile{}
+	projectConfigs := map[string]ProjectConfig{}
+	problems := []ValidationError{}
+	resolver := &configResolver{docs: docs}
+	for i, doc := range docs {
+		file := doc.file
+		for j := range files {
+			if files[j].Name == file.Name {
+				files[j].Overridden = true
+			}
+		}
+		file.Partial = isPartialConfig(file.Name)
+		files = append(files, file)
+		if file.Partial {
+			continue
+		}
+
+		merged, err := resolver.resolve(doc, i)
+		var problem ValidationError
+		if errors.As(err, &problem) {
+			problem.file = file
+			problems = append(problems, problem)
+			continue
+		}
+		config, configProblems := validateConfigDoc(file, merged)
+		if len(configProblems) > 0 {
+			for _, problem := range configProblems {
+				problem.file = file
+				problems = append(problems, problem)
+			}
+			continue
+		}
+		config.RootPath = currentDirectory
+		config.Name = file.Name
+		config.Source = file.Source
+		config.Path = file.Path
+		config.Inherits = merged.inherits
+		projectConfigs[file.Name] = config
+	}
+
+	slices.SortStableFunc(files, func(a, b ConfigFile) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return files, projectConfigs, problems
+}
+
+// withConfigFileOnly limits the project configs to the withConfigFile one,
+// which is either a path to the toml file or a config name in the search path
+func withConfigFileOnly(
+	projectConfigs map[string]ProjectConfig, withConfigFile string,
+) (map[string]ProjectConfig, error) {
+	config, exists := projectConfigs[filepath.Base(withConfigFile)]
+	if !exists {
+		return nil, fmt.Errorf("project config %s not found", withConfigFile)
+	}
+	return map[string]ProjectConfig{config.Name: config}, nil
+}
@@ -0,0 +1,268 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type packageJSON struct {
+	Name string `json:"name"`
+	// Workspaces is either a list of globs or the yarn {"packages": [...]} object
+	Workspaces json.RawMessage `json:"workspaces"`
+}
+
+type tsconfigJSON struct {
+	References []struct {
+		Path string `json:"path"`
+	} `json:"references"`
+}
+
+// workspacePackage is the project root relative directory of the workspace package
+type workspacePackage struct {
+	dir  string
+	name string
+}
+
+// buildTSWorkspaceFiles groups the files by the workspace package keyed by the package.json name.
+// Workspace packages are discovered from the package.json workspaces, pnpm-workspace.yaml
+// and tsconfig.json project references, files outside of them belong to the root package.
+// Projects without workspaces are grouped by directory
+func (pc *ProjectConfig) buildTSWorkspaceFiles() (map[string][]string, error) {
+	workspaces, err := tsWorkspacePackages(pc.RootPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(workspaces) == 0 {
+		directory := *pc
+		directory.ModuleMatch = "directory"
+		return directory.BuildPackageFiles()
+	}
+
+	rootName := "."
+	if root, err := readPackageJSON(filepath.Join(pc.RootPath, "package.json")); err == nil && root.Name != "" {
+		rootName = root.Name
+	}
+
+	packageFileMap := map[string][]string{}
+	if err := pc.walkFilterFiles(func(relPath string) error {
+		if slices.Contains(strings.Split(filepath.ToSlash(relPath), "/"), "node_modules") {
+			return nil
+		}
+		key := rootName
+		for _, workspace := range workspaces {
+			if strings.HasPrefix(relPath, workspace.dir+string(filepath.Separator)) {
+				key = workspace.name
+				break
+			}
+		}
+		packageFileMap[key] = append(packageFileMap[key], relPath)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return packageFileMap, nil
+}
+
+// tsWorkspacePackages returns the workspace packages sorted from the deepest directory
+func tsWorkspacePackages(rootPath string) ([]workspacePackage, error) {
+	patterns := []string{}
+
+	root, err := readPackageJSON(filepath.Join(rootPath, "package.json"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if len(root.Workspaces) > 0 {
+		var workspaces []string
+		if err := json.Unmarshal(root.Workspaces, &workspaces); err != nil {
+			var yarnWorkspaces struct {
+				Packages []string `json:"packages"`
+			}
+			if err := json.Unmarshal(root.Workspaces, &yarnWorkspaces); err != nil {
+				return nil, fmt.Errorf("parse package.json workspaces: %w", err)
+			}
+			workspaces = yarnWorkspaces.Packages
+		}
+		patterns = append(patterns, workspaces...)
+	}
+
+	content, err := os.ReadFile(filepath.Join(rootPath, "pnpm-workspace.yaml"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		var pnpmWorkspace struct {
+			Packages []string `yaml:"packages"`
+		}
+		if err := yaml.Unmarshal(content, &pnpmWorkspace); err != nil {
+			return nil, fmt.Errorf("parse pnpm-workspace.yaml: %w", err)
+		}
+		patterns = append(patterns, pnpmWorkspace.Packages...)
+	}
+
+	dirs, err := expandWorkspacePatterns(rootPath, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err = os.ReadFile(filepath.Join(rootPath, "tsconfig.json"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		var tsconfig tsconfigJSON
+		if err := json.Unmarshal(stripJSONComments(content), &tsconfig); err != nil {
+			return nil, fmt.Errorf("parse tsconfig.json: %w", err)
+		}
+		for _, reference := range tsconfig.References {
+			dir := filepath.Clean(filepath.FromSlash(reference.Path))
+			if strings.HasSuffix(dir, ".json") {
+				dir = filepath.Dir(dir)
+			}
+			if dir != "." && isDir(filepath.Join(rootPath, dir)) && !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	workspaces := []workspacePackage{}
+	for _, dir := range dirs {
+		workspace := workspacePackage{dir: dir, name: filepath.ToSlash(dir)}
+		pkg, err := readPackageJSON(filepath.Join(rootPath, dir, "package.json"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if pkg.Name != "" {
+			workspace.name = pkg.Name
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	slices.SortStableFunc(workspaces, func(a, b workspacePackage) int {
+		return pathDepth(b.dir) - pathDepth(a.dir)
+	})
+	return workspaces, nil
+}
+
+// expandWorkspacePatterns returns the directories having package.json matching the workspace globs,
+// "**" matches any directory depth and the "!" prefixed patterns exclude the matched directories
+func expandWorkspacePatterns(rootPath string, patterns []string) ([]string, error) {
+	dirs := []string{}
+	excluded := []string{}
+	for _, pattern := range patterns {
+		exclude := strings.HasPrefix(pattern, "!")
+		pattern = filepath.Clean(filepath.FromSlash(strings.TrimPrefix(pattern, "!")))
+
+		matches := []string{}
+		if prefix, _, recursive := strings.Cut(pattern, "**"); recursive {
+			prefix = filepath.Clean(prefix)
+			if err := filepath.WalkDir(filepath.Join(rootPath, prefix), func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					if errors.Is(err, fs.ErrNotExist) {
+						return nil
+					}
+					return err
+				}
+				if d.IsDir() && d.Name() == "node_modules" {
+					return filepath.SkipDir
+				}
+				if d.IsDir() {
+					matches = append(matches, path)
+				}
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+		} else {
+			var err error
+			if matches, err = filepath.Glob(filepath.Join(rootPath, pattern)); err != nil {
+				return nil, fmt.Errorf("workspace pattern %s: %w", pattern, err)
+			}
+		}
+
+		for _, match := range matches {
+			if !isFile(filepath.Join(match, "package.json")) {
+				continue
+			}
+			dir, err := filepath.Rel(rootPath, match)
+			if err != nil {
+				return nil, err
+			}
+			if dir == "." {
+				continue
+			}
+			if exclude {
+				excluded = append(excluded, dir)
+			} else if !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return slices.DeleteFunc(dirs, func(dir string) bool {
+		return slices.Contains(excluded, dir)
+	}), nil
+}
+
+func readPackageJSON(path string) (packageJSON, error) {
+	pkg := packageJSON{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return pkg, err
+	}
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return pkg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return pkg, nil
+}
+
+// stripJSONComments removes the comments and trailing commas allowed in tsconfig.json
+func stripJSONComments(content []byte) []byte {
+	stripped := []byte{}
+	inString := false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case inString:
+			stripped = append(stripped, c)
+			if c == '\\' && i+1 < len(content) {
+				i++
+				stripped = append(stripped, content[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			stripped = append(stripped, c)
+		case c == '/' && i+1 < len(content) && content[i+1] == '/':
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			if i < len(content) {
+				stripped = append(stripped, '\n')
+			}
+		case c == '/' && i+1 < len(content) && content[i+1] == '*':
+			i += 2
+			for i+1 < len(content) && (content[i] != '*' || content[i+1] != '/') {
+				i++
+			}
+			i++
+		case c == '}' || c == ']':
+			// The comments are stripped already, so the comma is the last non-space byte before the closing one
+			last := len(bytes.TrimRight(stripped, " \t\r\n")) - 1
+			if last >= 0 && stripped[last] == ',' {
+				stripped = append(stripped[:last], stripped[last+1:]...)
+			}
+			stripped = append(stripped, c)
+		default:
+			stripped = append(stripped, c)
+		}
+	}
+	return stripped
+}
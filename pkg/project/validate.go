@@ -0,0 +1,263 @@
+package project
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// ModuleMatchModes are the supported module_match values, see BuildPackageFiles
+var ModuleMatchModes = []string{
+	"directory", "go_package", "python_package", "ts_workspace", "rust_crate", "jvm_package",
+}
+
+// ValidationError is a project config problem at the file position,
+// Line and Column are zero for the problems of the missing keys
+type ValidationError struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+
+	// file is the project config the problem fails to load
+	file ConfigFile
+}
+
+func (e ValidationError) Error() string {
+	position := e.Path
+	if e.Line > 0 {
+		position = fmt.Sprintf("%s:%d:%d", e.Path, e.Line, e.Column)
+	}
+	if e.Key == "" {
+		return position + ": " + e.Message
+	}
+	return position + ": " + e.Key + ": " + e.Message
+}
+
+// joinProblems returns the problems as one error
+func joinProblems(problems []ValidationError) error {
+	errs := make([]error, len(problems))
+	for i, problem := range problems {
+		errs[i] = problem
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateProjectConfigs returns the problems of every project config in the search path
+// and the explicit withConfigFile path, including the overridden ones
+func ValidateProjectConfigs(currentDirectory, withConfigFile string) ([]ValidationError, error) {
+	docs, problems, err := readConfigDocs(currentDirectory, withConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	_, _, buildProblems := buildProjectConfigs(currentDirectory, docs)
+	return append(problems, buildProblems...), nil
+}
+
+// ValidateProjectConfigContent returns the problems of the project config toml content,
+// resolving its extends and include references in the search path as if it was loaded with -l name
+func ValidateProjectConfigContent(currentDirectory, name string, content []byte) ([]ValidationError, error) {
+	docs, _, err := readConfigDocs(currentDirectory, "")
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseConfigDoc(ConfigFile{Name: name, Source: SourceFile, Path: name}, content)
+	var problem ValidationError
+	if errors.As(err, &problem) {
+		return []ValidationError{problem}, nil
+	}
+	_, _, problems := buildProjectConfigs(currentDirectory, append(docs, doc))
+	return slices.DeleteFunc(problems, func(problem ValidationError) bool {
+		return problem.Path != name
+	}), nil
+}
+
+// validateConfigDoc checks the merged document keys and value types against the ProjectConfig fields,
+// then decodes it and checks the values
+func validateConfigDoc(file ConfigFile, merged mergedDoc) (ProjectConfig, []ValidationError) {
+	problemAt := func(key []string, format string, a ...any) ValidationError {
+		// Missing keys are reported at the closest merged table
+		for i := len(key); i > 0; i-- {
+			if origin, exists := merged.origins[formatKey(key[:i])]; exists {
+				origin.Key = formatKey(key)
+				origin.Message = fmt.Sprintf(format, a...)
+				return origin
+			}
+		}
+		return ValidationError{Path: file.Path, Key: formatKey(key), Message: fmt.Sprintf(format, a...)}
+	}
+
+	problems := []ValidationError{}
+	validateValue(merged.values, reflect.TypeFor[ProjectConfig](), nil, problemAt, &problems)
+	if len(problems) > 0 {
+		sortProblems(problems)
+		return ProjectConfig{}, problems
+	}
+	config, err := decodeConfigDoc(file, merged.values)
+	if err != nil {
+		return config, []ValidationError{{Path: file.Path, Message: err.Error()}}
+	}
+
+	if len(config.FileFilter) == 0 {
+		problems = append(problems, problemAt([]string{"file_filter"}, "at least one file suffix is required"))
+	}
+	if !slices.Contains(ModuleMatchModes, config.ModuleMatch) {
+		problems = append(problems, problemAt([]string{"module_match"},
+			"unknown module match mode %q, expected one of %s",
+			config.ModuleMatch, strings.Join(ModuleMatchModes, ", "),
+		))
+	}
+	if config.Chunking.MaxTokens < 0 {
+		problems = append(problems, problemAt([]string{"chunking", "max_tokens"}, "must not be negative"))
+	}
+	if config.Chunking.ChunkTokens < 0 {
+		problems = append(problems, problemAt([]string{"chunking", "chunk_tokens"}, "must not be negative"))
+	}
+
+	if _, exists := config.Prompts["default"]; !exists {
+		problems = append(problems, problemAt([]string{"prompts", "default"}, "default prompts are required"))
+	}
+	for _, model := range slices.Sorted(maps.Keys(config.Prompts)) {
+		if model != "default" {
+			if _, err := regexp.Compile(model); err != nil {
+				problems = append(problems, problemAt([]string{"prompts", model}, "invalid model regexp: %v", err))
+			}
+		}
+		problems = append(problems, validatePrompts(config.Prompts[model], []string{"prompts", model}, true, problemAt)...)
+	}
+	for _, pathPrompts := range config.PathPrompts {
+		if len(pathPrompts.Paths) == 0 {
+			problems = append(problems, problemAt([]string{"path_prompts", "paths"}, "at least one path pattern is required"))
+		}
+		problems = append(problems, validatePrompts(pathPrompts.Prompts, []string{"path_prompts", "prompts"}, false, problemAt)...)
+	}
+	sortProblems(problems)
+	return config, problems
+}
+
+func sortProblems(problems []ValidationError) {
+	slices.SortStableFunc(problems, func(a, b ValidationError) int {
+		return cmp.Or(strings.Compare(a.Path, b.Path), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+}
+
+// validatePrompts reports the invalid prompt templates,
+// and the missing code and package prompts if they are required
+func validatePrompts(
+	prompts ProjectConfigPrompts, key []string, required bool,
+	problemAt func(key []string, format string, a ...any) ValidationError,
+) []ValidationError {
+	problems := []ValidationError{}
+	value := reflect.ValueOf(prompts)
+	for i := range value.NumField() {
+		promptKey := append(slices.Clone(key), tomlKey(value.Type().Field(i)))
+		text := reflect.Indirect(value.Field(i))
+		if !text.IsValid() || text.String() == "" {
+			if required && (promptKey[len(key)] == "code" || promptKey[len(key)] == "package") {
+				problems = append(problems, problemAt(promptKey, "prompt is required"))
+			}
+			continue
+		}
+		if strings.Contains(text.String(), "{{") {
+			if _, err := template.New(formatKey(promptKey)).Parse(text.String()); err != nil {
+				problems = append(problems, problemAt(promptKey, "invalid prompt template: %v", err))
+			}
+		}
+	}
+	return problems
+}
+
+// validateValue reports the unknown keys and the values not matching the field types
+func validateValue(
+	value any, t reflect.Type, key []string,
+	problemAt func(key []string, format string, a ...any) ValidationError,
+	problems *[]ValidationError,
+) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			*problems = append(*problems, problemAt(key, "expected a string, got %s", tomlTypeName(value)))
+		}
+	case reflect.Int:
+		if _, ok := value.(int64); !ok {
+			*problems = append(*problems, problemAt(key, "expected an integer, got %s", tomlTypeName(value)))
+		}
+	case reflect.Slice:
+		items, ok := value.([]any)
+		if !ok {
+			*problems = append(*problems, problemAt(key, "expected an array, got %s", tomlTypeName(value)))
+			return
+		}
+		for _, item := range items {
+			validateValue(item, t.Elem(), key, problemAt, problems)
+		}
+	case reflect.Map:
+		table, ok := value.(map[string]any)
+		if !ok {
+			*problems = append(*problems, problemAt(key, "expected a table, got %s", tomlTypeName(value)))
+			return
+		}
+		for _, name := range slices.Sorted(maps.Keys(table)) {
+			validateValue(table[name], t.Elem(), append(slices.Clone(key), name), problemAt, problems)
+		}
+	case reflect.Struct:
+		table, ok := value.(map[string]any)
+		if !ok {
+			*problems = append(*problems, problemAt(key, "expected a table, got %s", tomlTypeName(value)))
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for i := range t.NumField() {
+			if name := tomlKey(t.Field(i)); name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(table)) {
+			fieldKey := append(slices.Clone(key), name)
+			field, exists := fields[name]
+			if !exists {
+				*problems = append(*problems, problemAt(fieldKey, "unknown key"))
+				continue
+			}
+			validateValue(table[name], field, fieldKey, problemAt, problems)
+		}
+	}
+}
+
+// tomlKey returns the toml tag key of the struct field, "-" for the fields without one
+func tomlKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	if name == "" {
+		return "-"
+	}
+	return name
+}
+
+func tomlTypeName(value any) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case int64:
+		return "an integer"
+	case float64:
+		return "a float"
+	case bool:
+		return "a boolean"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "a table"
+	default:
+		return "a datetime"
+	}
+}
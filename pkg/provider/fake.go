@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// FakeRule overrides the fake model response for the prompts matching the Match regular expression.
+// Error takes precedence over Response, Empty returns an empty response,
+// otherwise the default summary is returned.
+// Times limits how many times the rule is applied, zero means unlimited
+type FakeRule struct {
+	Match    string `json:"match" toml:"match"`
+	Response string `json:"response,omitempty" toml:"response"`
+	Empty    bool   `json:"empty,omitempty" toml:"empty"`
+	Error    string `json:"error,omitempty" toml:"error"`
+	Times    int    `json:"times,omitempty" toml:"times"`
+}
+
+type fakeRule struct {
+	FakeRule
+	re   *regexp.Regexp
+	used int
+}
+
+// FakeModel is an offline model returning deterministic summaries derived from the prompt content,
+// which can be scripted with rules to return the specific, empty responses or errors
+type FakeModel struct {
+	mu    sync.Mutex
+	rules []*fakeRule
+	calls int
+}
+
+var _ llms.Model = (*FakeModel)(nil)
+
+func NewFakeModel(rules ...FakeRule) (*FakeModel, error) {
+	m := &FakeModel{}
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("compile fake rule %s: %w", rule.Match, err)
+		}
+		m.rules = append(m.rules, &fakeRule{FakeRule: rule, re: re})
+	}
+	return m, nil
+}
+
+// LoadFakeRules reads the toml file with the [[rules]] array of FakeRule
+func LoadFakeRules(path string) ([]FakeRule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	script := struct {
+		Rules []FakeRule `toml:"rules"`
+	}{}
+	if err := toml.Unmarshal(content, &script); err != nil {
+		return nil, err
+	}
+	return script.Rules, nil
+}
+
+// Calls returns the count of the GenerateContent calls
+func (m *FakeModel) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+func (m *FakeModel) GenerateContent(
+	ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	prompt := strings.Builder{}
+	for _, message := range messages {
+		for _, part := range message.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				prompt.WriteString(text.Text)
+			}
+		}
+	}
+
+	response, err := m.respond(prompt.String())
+	if err != nil {
+		return nil, err
+	}
+	return &llms.ContentResponse{
+		Choices: []*llms.ContentChoice{{
+			Content: response,
+			GenerationInfo: map[string]any{
+				"PromptTokens":     len(strings.Fields(prompt.String())),
+				"CompletionTokens": len(strings.Fields(response)),
+			},
+		}},
+	}, nil
+}
+
+func (m *FakeModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func (m *FakeModel) respond(prompt string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+
+	for _, rule := range m.rules {
+		if rule.Times > 0 && rule.used >= rule.Times {
+			continue
+		}
+		if !rule.re.MatchString(prompt) {
+			continue
+		}
+		rule.used++
+		if rule.Error != "" {
+			return "", errors.New(rule.Error)
+		}
+		if rule.Response != "" || rule.Empty {
+			return rule.Response, nil
+		}
+		break
+	}
+	return fakeSummary(prompt), nil
+}
+
+var (
+	codeBlockRegexp   = regexp.MustCompile("(?s)```\n(.*?)\n```")
+	declarationRegexp = regexp.MustCompile(
+		`(?m)^\s*(?:export\s+)?(?:func|type|class|def|interface|struct|fn)\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`,
+	)
+)
+
+// fakeSummary describes the last code block of the prompt, or the whole prompt if there is none
+func fakeSummary(prompt string) string {
+	content := prompt
+	if matches := codeBlockRegexp.FindAllStringSubmatch(prompt, -1); len(matches) > 0 {
+		content = matches[len(matches)-1][1]
+	}
+	hash := sha256.Sum256([]byte(content))
+
+	summary := strings.Builder{}
+	fmt.Fprintf(&summary, "# Fake summary %s\n\n", hex.EncodeToString(hash[:])[:12])
+	fmt.Fprintf(&summary, "- lines: %d\n", strings.Count(content, "\n")+1)
+	fmt.Fprintf(&summary, "- bytes: %d\n", len(content))
+	declarations := []string{}
+	for _, match := range declarationRegexp.FindAllStringSubmatch(content, -1) {
+		declarations = append(declarations, match[1])
+	}
+	if len(declarations) > 0 {
+		fmt.Fprintf(&summary, "- declarations: %s\n", strings.Join(declarations, ", "))
+	}
+	return summary.String()
+}
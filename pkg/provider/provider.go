@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+const (
+	OpenAI    = "openai"
+	Ollama    = "ollama"
+	Anthropic = "anthropic"
+	// Fake is the offline deterministic model for dry runs and tests
+	Fake = "fake"
+)
+
+var Providers = []string{OpenAI, Ollama, Anthropic, Fake}
+
+// Settings holds the provider specific options,
+// options of the other providers are ignored
+type Settings struct {
+	OpenAIAPIVersion   string `json:"openai_api_version,omitempty"`
+	OpenAIOrganization string `json:"openai_organization,omitempty"`
+
+	OllamaKeepAlive string `json:"ollama_keep_alive,omitempty"`
+	OllamaNumCtx    int    `json:"ollama_num_ctx,omitempty"`
+
+	AnthropicMaxTokens int    `json:"anthropic_max_tokens,omitempty"`
+	AnthropicBeta      string `json:"anthropic_beta,omitempty"`
+
+	FakeRules     []FakeRule `json:"fake_rules,omitempty"`
+	FakeRulesPath string     `json:"-"`
+}
+
+// New creates the model of the named provider (OpenAI compatible if empty)
+// along with the call options suitable for it
+func New(
+	name, url, token, model string, settings Settings,
+) (llms.Model, []llms.CallOption, error) {
+	switch strings.ToLower(name) {
+	case OpenAI, "":
+		apiVersion := settings.OpenAIAPIVersion
+		if apiVersion == "" {
+			apiVersion = "v1"
+		}
+		opts := []openai.Option{
+			openai.WithBaseURL(url),
+			openai.WithToken(token),
+			openai.WithModel(model),
+			openai.WithAPIVersion(apiVersion),
+		}
+		if settings.OpenAIOrganization != "" {
+			opts = append(opts, openai.WithOrganization(settings.OpenAIOrganization))
+		}
+		llm, err := openai.New(opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("openai.New: %w", err)
+		}
+		return llm, []llms.CallOption{
+			llms.WithRepetitionPenalty(0.7),
+		}, nil
+
+	case Ollama:
+		opts := []ollama.Option{
+			ollama.WithModel(model),
+		}
+		if url != "" {
+			opts = append(opts, ollama.WithServerURL(url))
+		}
+		if settings.OllamaKeepAlive != "" {
+			opts = append(opts, ollama.WithKeepAlive(settings.OllamaKeepAlive))
+		}
+		if settings.OllamaNumCtx > 0 {
+			opts = append(opts, ollama.WithRunnerNumCtx(settings.OllamaNumCtx))
+		}
+		llm, err := ollama.New(opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ollama.New: %w", err)
+		}
+		return llm, nil, nil
+
+	case Anthropic:
+		opts := []anthropic.Option{
+			anthropic.WithToken(token),
+			anthropic.WithModel(model),
+		}
+		if url != "" {
+			opts = append(opts, anthropic.WithBaseURL(url))
+		}
+		if settings.AnthropicBeta != "" {
+			opts = append(opts, anthropic.WithAnthropicBetaHeader(settings.AnthropicBeta))
+		}
+		llm, err := anthropic.New(opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("anthropic.New: %w", err)
+		}
+		callOptions := []llms.CallOption{}
+		if settings.AnthropicMaxTokens > 0 {
+			callOptions = append(callOptions, llms.WithMaxTokens(settings.AnthropicMaxTokens))
+		}
+		return llm, callOptions, nil
+
+	case Fake:
+		rules := settings.FakeRules
+		if settings.FakeRulesPath != "" {
+			fileRules, err := LoadFakeRules(settings.FakeRulesPath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("load fake rules: %w", err)
+			}
+			rules = append(rules, fileRules...)
+		}
+		llm, err := NewFakeModel(rules...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return llm, nil, nil
+
+	default:
+		return nil, nil, fmt.Errorf(
+			"unknown LLM provider %s, available providers: %s",
+			name, strings.Join(Providers, ", "),
+		)
+	}
+}
@@ -0,0 +1,211 @@
+package reflexia
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JackBekket/reflexia/internal/github"
+	packagerunner "github.com/JackBekket/reflexia/pkg/package_runner"
+	"github.com/JackBekket/reflexia/pkg/project"
+	"github.com/go-git/go-git/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const ManifestFilename = ".reflexia_manifest.json"
+
+// Manifest records the last documented state of the projects.
+// It is written to the workdir, or to the output root of the separate output runs, after every successful incremental run,
+// so it is committed to the _autodoc branch along with the generated documentation.
+// The remote runs read it from the cloned base branch, so they see it only after the documentation PR is merged
+type Manifest struct {
+	// Commit is the repository HEAD at the time of the run, empty outside of git repositories
+	Commit string `json:"commit,omitempty"`
+	// Projects are keyed by the project config name prefixed with the workdir relative project root,
+	// e.g. "go.toml" or "web/ts.toml"
+	Projects  map[string]ManifestProject `json:"projects"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+}
+
+type ManifestProject struct {
+	// Files maps the file paths relative to the project root to their content sha256
+	Files map[string]string `json:"files"`
+	// Packages are the documented package files
+	Packages map[string][]string `json:"packages"`
+	// Summaries are the package summaries used for the project README of the partial runs
+	Summaries map[string]string `json:"summaries,omitempty"`
+}
+
+// ReadManifest returns nil manifest if the workdir has none
+func ReadManifest(rootPath string) (*Manifest, error) {
+	return readManifest(packagerunner.DirOutput(rootPath))
+}
+
+// readManifest reads the manifest of the output root, which is the workdir for the in-place runs
+func readManifest(output packagerunner.Output) (*Manifest, error) {
+	content, err := output.ReadFile(ManifestFilename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %w", ManifestFilename, err)
+	}
+	return manifest, nil
+}
+
+func (m Manifest) Write(rootPath string) error {
+	return m.write(packagerunner.DirOutput(rootPath))
+}
+
+func (m Manifest) write(output packagerunner.Output) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return output.WriteFile(ManifestFilename, append(content, '\n'))
+}
+
+// manifestKey returns the workdir relative project root joined with the project config name
+func manifestKey(workdir string, projectConfig *project.ProjectConfig) string {
+	relRoot, err := filepath.Rel(workdir, projectConfig.RootPath)
+	if err != nil {
+		relRoot = "."
+	}
+	return path.Join(filepath.ToSlash(relRoot), projectConfig.Name)
+}
+
+func hashPackageFiles(rootPath string, pkgFiles map[string][]string) (map[string]string, error) {
+	hashes := map[string]string{}
+	for _, files := range pkgFiles {
+		for _, relPath := range files {
+			content, err := os.ReadFile(filepath.Join(rootPath, relPath))
+			if err != nil {
+				return nil, err
+			}
+			hash := sha256.Sum256(content)
+			hashes[relPath] = hex.EncodeToString(hash[:])
+		}
+	}
+	return hashes, nil
+}
+
+// changedPackages returns the sorted packages touched since the manifest was written:
+// the packages having files changed by the git diff since the manifest commit,
+// files with the content hash different from the manifest one,
+// or the file list different from the documented one.
+// The git diff is skipped if the manifest commit is not in the repository history,
+// which is always the case for the depth 1 clones of the remote runs, so only the hashes are compared there
+func changedPackages(
+	repo *git.Repository,
+	rootPath, commit string,
+	manifest ManifestProject,
+	pkgFiles map[string][]string,
+	hashes map[string]string,
+) []string {
+	changedFiles := map[string]bool{}
+	if repo != nil && commit != "" {
+		if paths, err := projectChangedFiles(repo, rootPath, commit); err != nil {
+			log.Warn().Err(err).Msg("git diff since the last documented commit, comparing file hashes only")
+		} else {
+			for _, path := range paths {
+				changedFiles[path] = true
+			}
+		}
+	}
+
+	pkgs := []string{}
+	for pkg, files := range pkgFiles {
+		documented, ok := manifest.Packages[pkg]
+		changed := !ok || !slices.Equal(slices.Sorted(slices.Values(files)), slices.Sorted(slices.Values(documented)))
+		for _, relPath := range files {
+			if changed {
+				break
+			}
+			changed = changedFiles[relPath] || hashes[relPath] != manifest.Files[relPath]
+		}
+		if changed {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	slices.Sort(pkgs)
+	return pkgs
+}
+
+// projectChangedFiles converts the changed files paths to be relative to the project root
+func projectChangedFiles(repo *git.Repository, rootPath, from string) ([]string, error) {
+	paths, err := github.ChangedFiles(repo, from)
+	if err != nil {
+		return nil, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("get worktree: %w", err)
+	}
+	absRoot, err := filepath.Abs(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	relPaths := []string{}
+	for _, path := range paths {
+		relPath, err := filepath.Rel(absRoot, filepath.Join(wt.Filesystem.Root(), path))
+		if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			continue
+		}
+		relPaths = append(relPaths, relPath)
+	}
+	return relPaths, nil
+}
+
+// updateManifestProject records the documented packages summaries,
+// keeping the previously documented state of the packages that were not run
+func updateManifestProject(
+	previous *ManifestProject,
+	pkgFiles map[string][]string,
+	hashes map[string]string,
+	summaries map[string]string,
+) ManifestProject {
+	manifest := ManifestProject{
+		Files:     map[string]string{},
+		Packages:  map[string][]string{},
+		Summaries: map[string]string{},
+	}
+	for pkg, files := range pkgFiles {
+		if summary, ok := summaries[pkg]; ok {
+			manifest.Packages[pkg] = files
+			manifest.Summaries[pkg] = summary
+			for _, relPath := range files {
+				manifest.Files[relPath] = hashes[relPath]
+			}
+			continue
+		}
+		if previous == nil {
+			continue
+		}
+		if files, ok := previous.Packages[pkg]; ok {
+			manifest.Packages[pkg] = files
+			if summary, ok := previous.Summaries[pkg]; ok {
+				manifest.Summaries[pkg] = summary
+			}
+			for _, relPath := range files {
+				if hash, ok := previous.Files[relPath]; ok {
+					manifest.Files[relPath] = hash
+				}
+			}
+		}
+	}
+	return manifest
+}
@@ -2,15 +2,24 @@ package reflexia
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/JackBekket/reflexia/internal/github"
+	"github.com/JackBekket/reflexia/pkg/cache"
 	"github.com/JackBekket/reflexia/pkg/config"
 	packagerunner "github.com/JackBekket/reflexia/pkg/package_runner"
 	"github.com/JackBekket/reflexia/pkg/project"
+	"github.com/JackBekket/reflexia/pkg/provider"
+	"github.com/JackBekket/reflexia/pkg/site"
 	"github.com/JackBekket/reflexia/pkg/store"
 	"github.com/JackBekket/reflexia/pkg/summarize"
 	"github.com/Swarmind/libagent/pkg/agent/simple"
@@ -18,7 +27,6 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/rs/zerolog/log"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 )
 
 type ReflexiaCall struct {
@@ -35,22 +43,73 @@ type ReflexiaCall struct {
 	UseEmbeddings    bool
 	OverwriteReadme  bool
 	OverwriteCache   bool
+	// Incremental runs only the packages changed since the last run recorded in the manifest
+	Incremental bool
+	// AllProjects documents every detected project config root instead of the chosen one
+	AllProjects bool
+	Parallelism int
+	// OutputRoot mirrors the generated files of the workdir package tree into the directory
+	// instead of writing them into the package directories
+	OutputRoot string
+	// Output receives the generated files by the workdir relative names, e.g. packagerunner.NewMemoryOutput(),
+	// it takes precedence over the OutputRoot
+	Output packagerunner.Output
+	// SiteOutput receives the static HTML documentation site of the documented packages if it is set
+	SiteOutput packagerunner.Output
+	// SiteFormat is the site.Formats layout of the SiteOutput: html, or the mkdocs and docusaurus markdown docs trees
+	SiteFormat string
+	// ReportTo receives the JSON lines report records as soon as the packages and projects are done,
+	// the run record is written last, see Report.Write
+	ReportTo io.Writer
 
 	Config      config.Config
 	AgentConfig agentConfig.Config
+	// Cache is opened from Config.CacheBackend and Config.CachePath if not set
+	Cache       cache.Cache
 	ChooserFunc func(map[string]*project.ProjectConfig) (*project.ProjectConfig, error)
 
 	PrintTo io.Writer
 }
 
 type ReflexiaArtifacts struct {
+	// PackageRunnerStats are combined for all of the projects,
+	// package keys are prefixed with the project key by the AllProjects run
 	PackageRunnerStats packagerunner.RunStats
 	PullRequestURL     *string
+	// UnchangedPackages are skipped by the incremental run
+	UnchangedPackages []string
+	Projects          []ProjectArtifacts
+	// ConfigRanking explains the project config choice among several candidates
+	ConfigRanking []project.ConfigScore
+}
+
+type ProjectArtifacts struct {
+	// Project is the workdir relative project root joined with the project config name
+	Project            string
+	RootPath           string
+	ProjectConfig      string
+	PackageRunnerStats packagerunner.RunStats
+	UnchangedPackages  []string
+	// Config is the chosen project config merged with the repository config
+	Config *project.ProjectConfig
+	// PackageFiles are all of the project packages including the unchanged ones
+	PackageFiles map[string][]string
 }
 
 func (o ReflexiaCall) Run(ctx context.Context) (ReflexiaArtifacts, error) {
 	artifacts := ReflexiaArtifacts{}
 
+	output := o.Output
+	if output == nil && o.OutputRoot != "" {
+		output = packagerunner.DirOutput(o.OutputRoot)
+	}
+	if output != nil && o.CreatePR {
+		return artifacts, errors.New("pull request requires the in-place output")
+	}
+	if o.SiteFormat != "" && !slices.Contains(site.Formats, o.SiteFormat) {
+		return artifacts, fmt.Errorf("unknown site format %q, expected one of %s", o.SiteFormat, strings.Join(site.Formats, ", "))
+	}
+
 	workdir, err := os.Getwd()
 	if err != nil {
 		return artifacts, fmt.Errorf("get current workdir: %w", err)
@@ -83,47 +142,109 @@ func (o ReflexiaCall) Run(ctx context.Context) (ReflexiaArtifacts, error) {
 		projectName = filepath.Base(filepath.Dir(workdir))
 	}
 
-	projectConfigVariants, err := project.GetProjectConfig(
-		workdir, o.WithConfigFile, o.LightCheck,
-	)
-	if err != nil {
-		cancelFunc()
-		return artifacts, fmt.Errorf("get project config: %w", err)
+	var projectConfigs []*project.ProjectConfig
+	if o.AllProjects {
+		projectConfigs, err = project.DetectProjects(workdir, o.WithConfigFile, o.LightCheck)
+		if err != nil {
+			cancelFunc()
+			return artifacts, fmt.Errorf("detect projects: %w", err)
+		}
+		if len(projectConfigs) == 0 {
+			cancelFunc()
+			return artifacts, errors.New("no projects detected")
+		}
+	} else {
+		projectConfigVariants, err := project.GetProjectConfig(
+			workdir, o.WithConfigFile, o.LightCheck,
+		)
+		if err != nil {
+			cancelFunc()
+			return artifacts, fmt.Errorf("get project config: %w", err)
+		}
+		projectConfig, err := o.ChooserFunc(projectConfigVariants)
+		if errors.Is(err, project.ErrNoProjectConfig) {
+			err = project.NoProjectConfigError(workdir)
+		}
+		if err != nil {
+			cancelFunc()
+			return artifacts, fmt.Errorf("choose project config: %w", err)
+		}
+		projectConfigs = []*project.ProjectConfig{projectConfig}
+		artifacts.ConfigRanking = projectConfig.Ranking
 	}
-	projectConfig, err := o.ChooserFunc(projectConfigVariants)
+
+	repositoryConfig, err := project.ReadRepositoryConfig(workdir)
 	if err != nil {
 		cancelFunc()
-		return artifacts, fmt.Errorf("choose project config: %w", err)
+		return artifacts, fmt.Errorf("read repository config: %w", err)
+	}
+	if repositoryConfig != nil {
+		for _, projectConfig := range projectConfigs {
+			if err := projectConfig.ApplyRepositoryConfig(repositoryConfig); err != nil {
+				cancelFunc()
+				return artifacts, fmt.Errorf("apply repository config: %w", err)
+			}
+		}
+	}
+
+	responseCache := o.Cache
+	if responseCache == nil {
+		responseCache, err = cache.Open(o.Config.CacheBackend, o.Config.CachePath)
+		if err != nil {
+			cancelFunc()
+			return artifacts, fmt.Errorf("open cache: %w", err)
+		}
+		defer func() {
+			if err := responseCache.Close(); err != nil {
+				log.Warn().Err(err).Msg("close cache")
+			}
+		}()
 	}
 
 	agent := &simple.Agent{}
 
-	llm, err := openai.New(
-		openai.WithBaseURL(o.AgentConfig.AIURL),
-		openai.WithToken(o.AgentConfig.AIToken),
-		openai.WithModel(o.AgentConfig.Model),
-		openai.WithAPIVersion("v1"),
+	llm, providerOptions, err := provider.New(
+		o.Config.LLMProvider,
+		o.AgentConfig.AIURL,
+		o.AgentConfig.AIToken,
+		o.AgentConfig.Model,
+		o.Config.LLMProviderSettings,
 	)
 	if err != nil {
 		cancelFunc()
-		return artifacts, fmt.Errorf("openai.New: %w", err)
+		return artifacts, fmt.Errorf("new LLM provider: %w", err)
 	}
-	agent.LLM = llm
+	agent.LLM = summarize.UsageModel{Model: llm}
 
-	summarizeService := &summarize.SummarizeService{
-		Agent: agent,
-		LlmOptions: []llms.CallOption{
-			llms.WithStopWords(
-				projectConfig.StopWords,
-			),
-			llms.WithRepetitionPenalty(0.7),
-		},
-		StopWords: projectConfig.StopWords,
-		Model:     o.AgentConfig.Model,
+	var prices summarize.PriceTable
+	if o.Config.LLMPricesPath != "" {
+		prices, err = summarize.LoadPriceTable(o.Config.LLMPricesPath)
+		if err != nil {
+			cancelFunc()
+			return artifacts, fmt.Errorf("load price table: %w", err)
+		}
+	}
+
+	// Stop words are set per project config
+	summarizeService := summarize.SummarizeService{
+		Agent:      agent,
+		LlmOptions: providerOptions,
+		Model:      o.AgentConfig.Model,
+		Project:    projectName,
+		Prices:     prices,
 		// Tests only
 		IgnoreCache:    false,
 		OverwriteCache: o.OverwriteCache,
-		CachePath:      o.Config.CachePath,
+		Cache:          responseCache,
+		Retry: summarize.RetryConfig{
+			MaxRetries:     o.Config.LLMMaxRetries,
+			InitialBackoff: o.Config.LLMRetryBackoff,
+			MaxBackoff:     o.Config.LLMRetryMaxBackoff,
+		},
+		RateLimiter: summarize.NewRateLimiter(
+			o.Config.LLMRequestsPerMinute,
+			o.Config.LLMTokensPerMinute,
+		),
 	}
 	var embeddingsService *store.EmbeddingsService
 	if o.UseEmbeddings {
@@ -155,29 +276,124 @@ func (o ReflexiaCall) Run(ctx context.Context) (ReflexiaArtifacts, error) {
 		}
 	}
 
-	pkgFiles, err := projectConfig.BuildPackageFiles()
+	// documentedRepo is used to diff the changes since the last documented commit
+	documentedRepo := repo
+	if o.RepositoryURL == "" {
+		documentedRepo, err = git.PlainOpenWithOptions(workdir, &git.PlainOpenOptions{DetectDotGit: true})
+		if err != nil {
+			documentedRepo = nil
+		}
+	}
+	// The manifest is kept along with the generated files
+	manifestOutput := output
+	if manifestOutput == nil {
+		manifestOutput = packagerunner.DirOutput(workdir)
+	}
+	manifest, err := readManifest(manifestOutput)
 	if err != nil {
 		cancelFunc()
-		return artifacts, fmt.Errorf("build package files: %w", err)
+		return artifacts, fmt.Errorf("read manifest: %w", err)
+	}
+	updatedManifest := Manifest{
+		Projects:  map[string]ManifestProject{},
+		UpdatedAt: time.Now().UTC(),
+	}
+	documentedCommit := ""
+	if manifest != nil {
+		documentedCommit = manifest.Commit
+		// Projects which are not run keep their documented state
+		maps.Copy(updatedManifest.Projects, manifest.Projects)
 	}
 
-	packageRunnerService := packagerunner.PackageRunnerService{
-		PkgFiles:          pkgFiles,
-		ProjectConfig:     projectConfig,
-		SummarizeService:  summarizeService,
-		EmbeddingsService: embeddingsService,
-		ExactPackages:     o.ExactPackages,
-		OverwriteReadme:   o.OverwriteReadme,
-		WithFileSummary:   o.WithFileSummary,
+	siteProjects := []site.Project{}
+	// The projects sharing a directory write their readmes into the same output
+	readmeNames := packagerunner.NewReadmeNames()
+	for _, projectConfig := range projectConfigs {
+		key := manifestKey(workdir, projectConfig)
+		var documented *ManifestProject
+		if manifest != nil {
+			if manifestProject, ok := manifest.Projects[key]; ok {
+				documented = &manifestProject
+			}
+		}
+		if o.AllProjects {
+			fmt.Fprintf(o.PrintTo, "Project %s\n", key)
+		}
 
-		Model:   o.AgentConfig.Model,
-		PrintTo: o.PrintTo,
+		var projectOutput packagerunner.Output
+		if output != nil {
+			relRoot, err := filepath.Rel(workdir, projectConfig.RootPath)
+			if err != nil {
+				cancelFunc()
+				return artifacts, fmt.Errorf("project %s output: %w", key, err)
+			}
+			projectOutput = packagerunner.SubOutput(output, relRoot)
+		}
+		projectArtifacts, manifestProject, err := o.runProject(ctx,
+			key,
+			projectConfig,
+			projectOutput,
+			readmeNames,
+			summarizeService,
+			embeddingsService,
+			documentedRepo,
+			documentedCommit,
+			documented,
+		)
+		if err != nil {
+			cancelFunc()
+			return artifacts, fmt.Errorf("run %s project: %w", key, err)
+		}
+		updatedManifest.Projects[key] = manifestProject
+		artifacts.Projects = append(artifacts.Projects, projectArtifacts)
+		siteProjects = append(siteProjects, siteProject(workdir, key, projectConfig, projectArtifacts, manifestProject))
+
+		if !o.AllProjects {
+			artifacts.PackageRunnerStats = projectArtifacts.PackageRunnerStats
+			artifacts.UnchangedPackages = projectArtifacts.UnchangedPackages
+			continue
+		}
+		relRoot, err := filepath.Rel(workdir, projectConfig.RootPath)
+		if err != nil {
+			relRoot = "."
+		}
+		artifacts.PackageRunnerStats.Merge(projectArtifacts.PackageRunnerStats, key+":", relRoot)
+		for _, pkg := range projectArtifacts.UnchangedPackages {
+			artifacts.UnchangedPackages = append(artifacts.UnchangedPackages, key+":"+pkg)
+		}
 	}
 
-	artifacts.PackageRunnerStats, err = packageRunnerService.RunPackages(ctx)
-	if err != nil {
-		cancelFunc()
-		return artifacts, fmt.Errorf("run packages: %w", err)
+	if documentedRepo != nil {
+		if updatedManifest.Commit, err = github.HeadCommit(documentedRepo); err != nil {
+			log.Warn().Err(err).Msg("get documented commit")
+		}
+	}
+	// The manifest is only needed by the next incremental run, the other runs don't leave it in the tree
+	if o.Incremental {
+		if err := updatedManifest.write(manifestOutput); err != nil {
+			cancelFunc()
+			return artifacts, fmt.Errorf("write manifest: %w", err)
+		}
+	}
+
+	if o.SiteOutput != nil {
+		sourceURL := ""
+		if o.RepositoryURL != "" {
+			if sourceURL, err = github.BlobURL(o.RepositoryURL, branch); err != nil {
+				cancelFunc()
+				return artifacts, fmt.Errorf("site source url: %w", err)
+			}
+		} else if absWorkdir, err := filepath.Abs(workdir); err == nil {
+			sourceURL = (&url.URL{Scheme: "file", Path: filepath.ToSlash(absWorkdir) + "/"}).String()
+		}
+		if err := site.Write(o.SiteOutput, site.Site{
+			Title:     projectName,
+			SourceURL: sourceURL,
+			Projects:  siteProjects,
+		}, o.SiteFormat); err != nil {
+			cancelFunc()
+			return artifacts, fmt.Errorf("render site: %w", err)
+		}
 	}
 
 	if o.CreatePR {
@@ -195,10 +411,128 @@ func (o ReflexiaCall) Run(ctx context.Context) (ReflexiaArtifacts, error) {
 		artifacts.PullRequestURL = &prURL
 	}
 
+	o.report(func(records reportRecords) error {
+		return records.writeRun(NewReport(artifacts))
+	})
 	cancelFunc()
 	return artifacts, nil
 }
 
+// report writes the JSON lines report records to the ReportTo if it is set
+func (o ReflexiaCall) report(write func(records reportRecords) error) {
+	if o.ReportTo == nil {
+		return
+	}
+	if err := write(newReportRecords(o.ReportTo)); err != nil {
+		log.Warn().Err(err).Msg("write report record")
+	}
+}
+
+// runProject documents the packages of one project config root
+// and returns its updated manifest state
+func (o ReflexiaCall) runProject(
+	ctx context.Context,
+	key string,
+	projectConfig *project.ProjectConfig,
+	output packagerunner.Output,
+	readmeNames *packagerunner.ReadmeNames,
+	summarizeService summarize.SummarizeService,
+	embeddingsService *store.EmbeddingsService,
+	repo *git.Repository,
+	documentedCommit string,
+	documented *ManifestProject,
+) (ProjectArtifacts, ManifestProject, error) {
+	artifacts := ProjectArtifacts{
+		Project:       key,
+		RootPath:      projectConfig.RootPath,
+		ProjectConfig: projectConfig.Name,
+		Config:        projectConfig,
+	}
+
+	summarizeService.LlmOptions = append([]llms.CallOption{
+		llms.WithStopWords(
+			projectConfig.StopWords,
+		),
+	}, summarizeService.LlmOptions...)
+	summarizeService.StopWords = projectConfig.StopWords
+
+	pkgFiles, err := projectConfig.BuildPackageFiles()
+	if err != nil {
+		return artifacts, ManifestProject{}, fmt.Errorf("build package files: %w", err)
+	}
+	artifacts.PackageFiles = pkgFiles
+	hashes, err := hashPackageFiles(projectConfig.RootPath, pkgFiles)
+	if err != nil {
+		return artifacts, ManifestProject{}, fmt.Errorf("hash package files: %w", err)
+	}
+
+	var pkgs []string
+	var pkgSummaries map[string]string
+	if documented != nil {
+		pkgSummaries = documented.Summaries
+	}
+	if o.Incremental {
+		if documented != nil {
+			pkgs = changedPackages(repo, projectConfig.RootPath, documentedCommit, *documented, pkgFiles, hashes)
+			for pkg := range pkgFiles {
+				if !slices.Contains(pkgs, pkg) {
+					artifacts.UnchangedPackages = append(artifacts.UnchangedPackages, pkg)
+				}
+			}
+			slices.Sort(artifacts.UnchangedPackages)
+		} else {
+			log.Info().Msgf("no %s manifest for %s project config, documenting all packages",
+				ManifestFilename, projectConfig.Name)
+		}
+	}
+
+	packageRunnerService := packagerunner.PackageRunnerService{
+		PkgFiles:          pkgFiles,
+		ProjectConfig:     projectConfig,
+		SummarizeService:  &summarizeService,
+		EmbeddingsService: embeddingsService,
+		ExactPackages:     o.ExactPackages,
+		Packages:          pkgs,
+		PackageSummaries:  pkgSummaries,
+		OverwriteReadme:   o.OverwriteReadme,
+		WithFileSummary:   o.WithFileSummary,
+		Parallelism:       o.Parallelism,
+		Output:            output,
+		ReadmeNames:       readmeNames,
+
+		Model:   o.AgentConfig.Model,
+		PrintTo: o.PrintTo,
+	}
+	if o.ReportTo != nil {
+		packageRunnerService.PackageDone = func(pkg string, stats packagerunner.RunStats) {
+			o.report(func(records reportRecords) error {
+				return records.writePackage(key, newReportPackage(
+					projectConfig, projectConfig.RootPath, pkg, pkgFiles[pkg], stats, false,
+				))
+			})
+		}
+	}
+
+	artifacts.PackageRunnerStats, err = packageRunnerService.RunPackages(ctx)
+	if err != nil {
+		return artifacts, ManifestProject{}, fmt.Errorf("run packages: %w", err)
+	}
+	o.report(func(records reportRecords) error {
+		for _, pkg := range artifacts.UnchangedPackages {
+			if err := records.writePackage(key, newReportPackage(
+				projectConfig, projectConfig.RootPath, pkg, pkgFiles[pkg], packagerunner.RunStats{}, true,
+			)); err != nil {
+				return err
+			}
+		}
+		return records.writeProject(newReportProject(artifacts))
+	})
+
+	return artifacts, updateManifestProject(
+		documented, pkgFiles, hashes, artifacts.PackageRunnerStats.PackageSummaries,
+	), nil
+}
+
 func simSearchTest(ctx context.Context, embeddingsService store.EmbeddingsService, testPrompt string) error {
 	results, err := embeddingsService.Store.SimilaritySearch(ctx, testPrompt, 2)
 	if err != nil {
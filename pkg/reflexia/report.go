@@ -0,0 +1,236 @@
+package reflexia
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	packagerunner "github.com/JackBekket/reflexia/pkg/package_runner"
+	"github.com/JackBekket/reflexia/pkg/project"
+)
+
+// Report formats, see Report.Write
+const (
+	ReportJSON  = "json"
+	ReportJSONL = "jsonl"
+)
+
+// Report is the machine-readable result of the run
+type Report struct {
+	Projects          []ReportProject       `json:"projects,omitempty"`
+	UnchangedPackages []string              `json:"unchanged_packages,omitempty"`
+	ConfigRanking     []project.ConfigScore `json:"config_ranking,omitempty"`
+	PullRequestURL    string                `json:"pull_request_url,omitempty"`
+	// Stats are combined for all of the projects
+	Stats packagerunner.RunStats `json:"stats"`
+}
+
+type ReportProject struct {
+	// Project is the workdir relative project root joined with the project config name
+	Project  string       `json:"project"`
+	RootPath string       `json:"root_path"`
+	Config   ReportConfig `json:"config"`
+	// Readme is the project README output path, empty if the project stage is skipped
+	Readme   string                 `json:"readme,omitempty"`
+	Summary  string                 `json:"summary,omitempty"`
+	Fallback bool                   `json:"fallback,omitempty"`
+	Empty    bool                   `json:"empty,omitempty"`
+	Packages []ReportPackage        `json:"packages,omitempty"`
+	Stats    packagerunner.RunStats `json:"stats"`
+}
+
+// ReportConfig is the chosen project config without the prompts
+type ReportConfig struct {
+	Name              string   `json:"name"`
+	Source            string   `json:"source"`
+	Path              string   `json:"path"`
+	Inherits          []string `json:"inherits,omitempty"`
+	RepositoryConfig  string   `json:"repository_config,omitempty"`
+	FileFilter        []string `json:"file_filter"`
+	ProjectRootFilter []string `json:"project_root_filter"`
+	ModuleMatch       string   `json:"module_match"`
+	Exclude           []string `json:"exclude,omitempty"`
+	Readme            string   `json:"readme"`
+	GeneratedReadme   string   `json:"generated_readme"`
+	FileSummary       string   `json:"file_summary"`
+}
+
+type ReportPackage struct {
+	Key string `json:"key"`
+	// Dir is the project root relative package directory
+	Dir     string       `json:"dir"`
+	Files   []ReportFile `json:"files"`
+	Summary string       `json:"summary,omitempty"`
+	// Outputs are the paths of the written README and file summary
+	Outputs  []string `json:"outputs,omitempty"`
+	Fallback bool     `json:"fallback,omitempty"`
+	Empty    bool     `json:"empty,omitempty"`
+	// Unchanged packages are skipped by the incremental run
+	Unchanged bool                 `json:"unchanged,omitempty"`
+	Usage     *packagerunner.Usage `json:"usage,omitempty"`
+}
+
+type ReportFile struct {
+	// Path is relative to the project root
+	Path     string               `json:"path"`
+	Summary  string               `json:"summary,omitempty"`
+	Fallback bool                 `json:"fallback,omitempty"`
+	Empty    bool                 `json:"empty,omitempty"`
+	Usage    *packagerunner.Usage `json:"usage,omitempty"`
+}
+
+// NewReport collects the report of the run artifacts
+func NewReport(artifacts ReflexiaArtifacts) Report {
+	report := Report{
+		UnchangedPackages: artifacts.UnchangedPackages,
+		ConfigRanking:     artifacts.ConfigRanking,
+		Stats:             artifacts.PackageRunnerStats,
+	}
+	if artifacts.PullRequestURL != nil {
+		report.PullRequestURL = *artifacts.PullRequestURL
+	}
+	for _, projectArtifacts := range artifacts.Projects {
+		report.Projects = append(report.Projects, newReportProject(projectArtifacts))
+	}
+	return report
+}
+
+func newReportProject(artifacts ProjectArtifacts) ReportProject {
+	stats := artifacts.PackageRunnerStats
+	reportProject := ReportProject{
+		Project:  artifacts.Project,
+		RootPath: artifacts.RootPath,
+		Readme:   stats.ProjectReadme,
+		Summary:  stats.ProjectSummary,
+		Fallback: stats.ProjectFallback,
+		Empty:    stats.ProjectEmpty,
+		Stats:    stats,
+	}
+	config := artifacts.Config
+	if config == nil {
+		return reportProject
+	}
+	reportProject.Config = ReportConfig{
+		Name:              config.Name,
+		Source:            config.Source,
+		Path:              config.Path,
+		Inherits:          config.Inherits,
+		RepositoryConfig:  config.RepositoryConfig,
+		FileFilter:        config.FileFilter,
+		ProjectRootFilter: config.ProjectRootFilter,
+		ModuleMatch:       config.ModuleMatch,
+		Exclude:           config.Exclude,
+		Readme:            config.Output.ReadmeFilename(),
+		GeneratedReadme:   config.Output.GeneratedReadmeFilename(),
+		FileSummary:       config.Output.FileSummaryFilename(),
+	}
+
+	for _, pkg := range slices.Sorted(maps.Keys(artifacts.PackageFiles)) {
+		reportProject.Packages = append(reportProject.Packages, newReportPackage(
+			config, artifacts.RootPath, pkg, artifacts.PackageFiles[pkg], stats,
+			slices.Contains(artifacts.UnchangedPackages, pkg),
+		))
+	}
+	return reportProject
+}
+
+func newReportPackage(
+	config *project.ProjectConfig,
+	rootPath, pkg string,
+	files []string,
+	stats packagerunner.RunStats,
+	unchanged bool,
+) ReportPackage {
+	reportPackage := ReportPackage{
+		Key:       pkg,
+		Dir:       filepath.ToSlash(config.PackageDir(files)),
+		Summary:   stats.PackageSummaries[pkg],
+		Outputs:   stats.PackageOutputs[pkg],
+		Fallback:  slices.Contains(stats.FallbackPackageResponses, pkg),
+		Empty:     slices.Contains(stats.EmptyPackageResponses, pkg),
+		Unchanged: unchanged,
+	}
+	if usage, ok := stats.PackageUsage[pkg]; ok {
+		reportPackage.Usage = &usage
+	}
+	for _, relPath := range files {
+		absPath := filepath.Join(rootPath, relPath)
+		reportFile := ReportFile{
+			Path:     filepath.ToSlash(relPath),
+			Summary:  stats.FileSummaries[relPath],
+			Fallback: slices.Contains(stats.FallbackFileResponses, absPath),
+			Empty:    slices.Contains(stats.EmptyFileResponses, absPath),
+		}
+		if usage, ok := stats.FileUsage[relPath]; ok {
+			reportFile.Usage = &usage
+		}
+		reportPackage.Files = append(reportPackage.Files, reportFile)
+	}
+	return reportPackage
+}
+
+// Write encodes the report as one indented JSON document,
+// or as the JSON lines of the package, project and run records for the jsonl format
+// in the order they are streamed to the ReflexiaCall.ReportTo, see reportRecords
+func (r Report) Write(w io.Writer, format string) error {
+	switch format {
+	case ReportJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case ReportJSONL:
+	default:
+		return fmt.Errorf("unknown report format %q, expected %s or %s", format, ReportJSON, ReportJSONL)
+	}
+
+	records := newReportRecords(w)
+	for _, reportProject := range r.Projects {
+		for _, reportPackage := range reportProject.Packages {
+			if err := records.writePackage(reportProject.Project, reportPackage); err != nil {
+				return err
+			}
+		}
+		if err := records.writeProject(reportProject); err != nil {
+			return err
+		}
+	}
+	return records.writeRun(r)
+}
+
+// reportRecords encodes the JSON lines report records with the type field:
+// the package records with the project key field, followed by their project record without the packages,
+// and the final run record with the combined stats
+type reportRecords struct {
+	encoder *json.Encoder
+}
+
+func newReportRecords(w io.Writer) reportRecords {
+	return reportRecords{encoder: json.NewEncoder(w)}
+}
+
+func (r reportRecords) writePackage(project string, reportPackage ReportPackage) error {
+	return r.encoder.Encode(struct {
+		Type    string `json:"type"`
+		Project string `json:"project"`
+		ReportPackage
+	}{"package", project, reportPackage})
+}
+
+func (r reportRecords) writeProject(reportProject ReportProject) error {
+	reportProject.Packages = nil
+	return r.encoder.Encode(struct {
+		Type string `json:"type"`
+		ReportProject
+	}{"project", reportProject})
+}
+
+func (r reportRecords) writeRun(report Report) error {
+	report.Projects = nil
+	return r.encoder.Encode(struct {
+		Type string `json:"type"`
+		Report
+	}{"run", report})
+}
@@ -0,0 +1,48 @@
+package reflexia
+
+import (
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/JackBekket/reflexia/pkg/project"
+	"github.com/JackBekket/reflexia/pkg/site"
+)
+
+// siteProject returns the site project of the documented state recorded in the manifest,
+// so the unchanged packages of the incremental run keep their summaries.
+// File summaries are known only for the files documented by this run
+func siteProject(
+	workdir, key string,
+	projectConfig *project.ProjectConfig,
+	projectArtifacts ProjectArtifacts,
+	manifestProject ManifestProject,
+) site.Project {
+	relRoot, err := filepath.Rel(workdir, projectConfig.RootPath)
+	if err != nil {
+		relRoot = "."
+	}
+	relRoot = filepath.ToSlash(relRoot)
+
+	siteProject := site.Project{
+		Key:     key,
+		Summary: projectArtifacts.PackageRunnerStats.ProjectSummary,
+	}
+	for _, pkg := range slices.Sorted(maps.Keys(manifestProject.Packages)) {
+		files := manifestProject.Packages[pkg]
+		sitePackage := site.Package{
+			Key:     pkg,
+			Dir:     path.Join(relRoot, filepath.ToSlash(projectConfig.PackageDir(files))),
+			Summary: manifestProject.Summaries[pkg],
+		}
+		for _, relPath := range files {
+			sitePackage.Files = append(sitePackage.Files, site.File{
+				Path:    path.Join(relRoot, filepath.ToSlash(relPath)),
+				Summary: projectArtifacts.PackageRunnerStats.FileSummaries[relPath],
+			})
+		}
+		siteProject.Packages = append(siteProject.Packages, sitePackage)
+	}
+	return siteProject
+}
@@ -0,0 +1,29 @@
+(function () {
+  var input = document.getElementById("search");
+  var results = document.getElementById("search-results");
+  if (!input || !window.searchIndex) {
+    return;
+  }
+  input.addEventListener("input", function () {
+    var terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    results.innerHTML = "";
+    if (terms.length === 0) {
+      return;
+    }
+    var matches = window.searchIndex.filter(function (entry) {
+      var text = (entry.title + " " + entry.text).toLowerCase();
+      return terms.every(function (term) { return text.indexOf(term) >= 0; });
+    }).slice(0, 20);
+    matches.forEach(function (entry) {
+      var item = document.createElement("li");
+      var link = document.createElement("a");
+      link.href = entry.path;
+      link.textContent = entry.title;
+      var kind = document.createElement("small");
+      kind.textContent = entry.kind;
+      item.appendChild(link);
+      item.appendChild(kind);
+      results.appendChild(item);
+    });
+  });
+})();
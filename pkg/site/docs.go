@@ -0,0 +1,243 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	packagerunner "github.com/JackBekket/reflexia/pkg/package_runner"
+	"gopkg.in/yaml.v2"
+)
+
+// Site formats, see Write
+const (
+	FormatHTML       = "html"
+	FormatMkDocs     = "mkdocs"
+	FormatDocusaurus = "docusaurus"
+)
+
+var Formats = []string{FormatHTML, FormatMkDocs, FormatDocusaurus}
+
+// docsDir is the markdown docs directory of the MkDocs and Docusaurus layouts
+const docsDir = "docs"
+
+// Write renders the site in the format, the empty format is html
+func Write(output packagerunner.Output, site Site, format string) error {
+	switch format {
+	case FormatHTML, "":
+		return Render(output, site)
+	case FormatMkDocs:
+		return RenderMkDocs(output, site)
+	case FormatDocusaurus:
+		return RenderDocusaurus(output, site)
+	}
+	return fmt.Errorf("unknown site format %q, expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+// RenderMkDocs writes the docs/ markdown tree and the mkdocs.yml with its nav section
+// following the package directories
+func RenderMkDocs(output packagerunner.Output, site Site) error {
+	site = assignDocs(site)
+	if err := writeDocs(packagerunner.SubOutput(output, docsDir), site, func(title, _ string) string {
+		return frontmatter(yaml.MapSlice{{Key: "title", Value: title}})
+	}, nil); err != nil {
+		return err
+	}
+
+	nav := []any{yaml.MapSlice{{Key: "Overview", Value: "index.md"}}}
+	nav = append(nav, mkdocsNav(packageTree(site))...)
+	content, err := yaml.Marshal(yaml.MapSlice{
+		{Key: "site_name", Value: site.Title},
+		{Key: "docs_dir", Value: docsDir},
+		{Key: "nav", Value: nav},
+	})
+	if err != nil {
+		return fmt.Errorf("encode mkdocs.yml: %w", err)
+	}
+	return output.WriteFile("mkdocs.yml", append([]byte("# Generated by Reflexia\n"), content...))
+}
+
+func mkdocsNav(nodes []*treeNode) []any {
+	nav := []any{}
+	for _, node := range nodes {
+		switch {
+		case len(node.Children) == 0:
+			nav = append(nav, yaml.MapSlice{{Key: node.Name, Value: node.Page}})
+		case node.Page != "":
+			// The section page goes first, MkDocs takes its title from the page
+			nav = append(nav, yaml.MapSlice{{Key: node.Name, Value: append([]any{node.Page}, mkdocsNav(node.Children)...)}})
+		default:
+			nav = append(nav, yaml.MapSlice{{Key: node.Name, Value: mkdocsNav(node.Children)}})
+		}
+	}
+	return nav
+}
+
+// RenderDocusaurus writes the docs/ markdown tree and the sidebars.js with the docs sidebar
+// following the package directories. The markdown is escaped for MDX
+func RenderDocusaurus(output packagerunner.Output, site Site) error {
+	site = assignDocs(site)
+	if err := writeDocs(packagerunner.SubOutput(output, docsDir), site, func(title, label string) string {
+		return frontmatter(yaml.MapSlice{{Key: "title", Value: title}, {Key: "sidebar_label", Value: label}})
+	}, escapeMDX); err != nil {
+		return err
+	}
+
+	items := []any{"index"}
+	items = append(items, docusaurusItems(packageTree(site))...)
+	content, err := json.MarshalIndent(map[string]any{"docs": items}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode sidebars.js: %w", err)
+	}
+	return output.WriteFile("sidebars.js", fmt.Appendf(nil, "// Generated by Reflexia\nmodule.exports = %s;\n", content))
+}
+
+type docusaurusCategory struct {
+	Type  string         `json:"type"`
+	Label string         `json:"label"`
+	Link  map[string]any `json:"link,omitempty"`
+	Items []any          `json:"items"`
+}
+
+func docusaurusItems(nodes []*treeNode) []any {
+	items := []any{}
+	for _, node := range nodes {
+		id := strings.TrimSuffix(node.Page, ".md")
+		if len(node.Children) == 0 {
+			items = append(items, map[string]any{"type": "doc", "id": id, "label": node.Name})
+			continue
+		}
+		category := docusaurusCategory{Type: "category", Label: node.Name, Items: docusaurusItems(node.Children)}
+		if node.Page != "" {
+			category.Link = map[string]any{"type": "doc", "id": id}
+		}
+		items = append(items, category)
+	}
+	return items
+}
+
+// assignDocs sets the docs relative markdown paths of the packages:
+// the first package of the directory is its index.md, the others are named by the package key
+func assignDocs(site Site) Site {
+	used := map[string]bool{"index.md": true}
+	projects := make([]Project, len(site.Projects))
+	for i, project := range site.Projects {
+		projects[i] = project
+		projects[i].Packages = make([]Package, len(project.Packages))
+		for j, pkg := range project.Packages {
+			dir := pkg.Dir
+			if dir == "." {
+				dir = ""
+			}
+			pkg.page = path.Join(dir, "index.md")
+			if used[pkg.page] {
+				slug := strings.Trim(slugRegexp.ReplaceAllString(pkg.Key, "_"), "._")
+				pkg.page = path.Join(dir, slug+".md")
+				for k := 2; used[pkg.page]; k++ {
+					pkg.page = path.Join(dir, fmt.Sprintf("%s_%d.md", slug, k))
+				}
+			}
+			used[pkg.page] = true
+			projects[i].Packages[j] = pkg
+		}
+	}
+	site.Projects = projects
+	return site
+}
+
+// writeDocs writes index.md with the project summaries and the package pages with their file summaries,
+// header renders the page frontmatter, escape is applied to the summaries if it is set
+func writeDocs(
+	output packagerunner.Output, site Site,
+	header func(title, label string) string,
+	escape func(string) string,
+) error {
+	if escape == nil {
+		escape = func(text string) string { return text }
+	}
+
+	var index strings.Builder
+	index.WriteString(header(site.Title, "Overview"))
+	for _, project := range site.Projects {
+		if len(site.Projects) > 1 {
+			fmt.Fprintf(&index, "## %s\n\n", project.Key)
+		}
+		if project.Summary != "" {
+			fmt.Fprintf(&index, "%s\n\n", escape(strings.TrimSpace(project.Summary)))
+		}
+		for _, pkg := range project.Packages {
+			fmt.Fprintf(&index, "- [%s](%s)\n", escape(pkg.Key), pkg.page)
+		}
+		index.WriteString("\n")
+	}
+	if err := output.WriteFile("index.md", []byte(index.String())); err != nil {
+		return fmt.Errorf("write index.md: %w", err)
+	}
+
+	for _, project := range site.Projects {
+		for _, pkg := range project.Packages {
+			var page strings.Builder
+			page.WriteString(header(pkg.Key, path.Base(pkg.Key)))
+			if source := sourceLink(site.SourceURL, pkg.Dir); source != "" {
+				fmt.Fprintf(&page, "Source: [%s](%s)\n\n", escape(pkg.Dir), source)
+			}
+			if pkg.Summary != "" {
+				fmt.Fprintf(&page, "%s\n\n", escape(strings.TrimSpace(pkg.Summary)))
+			}
+			if len(pkg.Files) > 0 {
+				page.WriteString("## Files\n\n")
+			}
+			for _, file := range pkg.Files {
+				title := escape(file.Path)
+				if source := sourceLink(site.SourceURL, file.Path); source != "" {
+					title = fmt.Sprintf("[%s](%s)", title, source)
+				}
+				fmt.Fprintf(&page, "### %s\n\n", title)
+				if file.Summary != "" {
+					fmt.Fprintf(&page, "%s\n\n", escape(strings.TrimSpace(file.Summary)))
+				}
+			}
+			if err := output.WriteFile(pkg.page, []byte(page.String())); err != nil {
+				return fmt.Errorf("write %s: %w", pkg.page, err)
+			}
+		}
+	}
+	return nil
+}
+
+func frontmatter(fields yaml.MapSlice) string {
+	content, err := yaml.Marshal(fields)
+	if err != nil {
+		// The string fields are always encodable
+		panic(err)
+	}
+	return "---\n" + string(content) + "---\n\n"
+}
+
+var mdxSpecialRegexp = regexp.MustCompile(`[<{}]`)
+
+// escapeMDX escapes the characters MDX parses as JSX and expressions outside of the code
+func escapeMDX(text string) string {
+	lines := strings.Split(text, "\n")
+	inCode := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		// The odd parts are the code spans, the text after the unpaired backtick is not
+		parts := strings.Split(line, "`")
+		for j := range parts {
+			if j%2 == 0 || j == len(parts)-1 {
+				parts[j] = mdxSpecialRegexp.ReplaceAllString(parts[j], `\$0`)
+			}
+		}
+		lines[i] = strings.Join(parts, "`")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,117 @@
+package site
+
+import (
+	"strings"
+	"testing"
+
+	packagerunner "github.com/JackBekket/reflexia/pkg/package_runner"
+)
+
+var docsSite = Site{
+	Title:     "sample",
+	SourceURL: "https://github.com/owner/sample/blob/main/",
+	Projects: []Project{{
+		Key:     "go.toml",
+		Summary: "Sample project",
+		Packages: []Package{
+			{Key: ".:main", Dir: ".", Summary: "Main package", Files: []File{{Path: "main.go", Summary: "Entry point"}}},
+			{Key: "internal/greeter:greeter", Dir: "internal/greeter", Summary: "Greets the `<user>` by {name}", Files: []File{
+				{Path: "internal/greeter/greeter.go", Summary: "Greets the user\n\n```go\nfunc Greet() {}\n```"},
+			}},
+			{Key: "internal/greeter:greeter_test", Dir: "internal/greeter"},
+		},
+	}},
+}
+
+func TestRenderMkDocs(t *testing.T) {
+	output := packagerunner.NewMemoryOutput()
+	if err := Write(output, docsSite, FormatMkDocs); err != nil {
+		t.Fatal(err)
+	}
+
+	names := strings.Join(output.Names(), " ")
+	if names != "docs/index.md docs/internal/greeter/index.md docs/internal/greeter/internal_greeter_greeter_test.md "+
+		"docs/main.md mkdocs.yml" {
+		t.Fatalf("unexpected docs files %s", names)
+	}
+	config, err := output.ReadFile("mkdocs.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `site_name: sample
+docs_dir: docs
+nav:
+- Overview: index.md
+- .:main: main.md
+- internal:
+  - greeter:
+    - internal/greeter/index.md
+    - internal/greeter:greeter_test: internal/greeter/internal_greeter_greeter_test.md
+`
+	if !strings.HasSuffix(string(config), expected) {
+		t.Errorf("unexpected mkdocs.yml:\n%s", config)
+	}
+	page, err := output.ReadFile("docs/internal/greeter/index.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"---\ntitle: internal/greeter:greeter\n---\n\n",
+		"Greets the `<user>` by {name}\n\n## Files\n\n",
+		"### [internal/greeter/greeter.go](https://github.com/owner/sample/blob/main/internal/greeter/greeter.go)\n\n",
+	} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("expected %q in the package page:\n%s", expected, page)
+		}
+	}
+}
+
+func TestRenderDocusaurus(t *testing.T) {
+	output := packagerunner.NewMemoryOutput()
+	if err := Write(output, docsSite, FormatDocusaurus); err != nil {
+		t.Fatal(err)
+	}
+
+	sidebars, err := output.ReadFile("sidebars.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`"docs": [
+    "index",
+    {
+      "id": "main",
+      "label": ".:main",
+      "type": "doc"
+    },
+    {
+      "type": "category",
+      "label": "internal",
+      "items": [
+        {
+          "type": "category",
+          "label": "greeter",
+          "link": {
+            "id": "internal/greeter/index",
+            "type": "doc"
+          },`,
+		`"id": "internal/greeter/internal_greeter_greeter_test"`,
+	} {
+		if !strings.Contains(string(sidebars), expected) {
+			t.Errorf("expected %q in sidebars.js:\n%s", expected, sidebars)
+		}
+	}
+	page, err := output.ReadFile("docs/internal/greeter/index.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"---\ntitle: internal/greeter:greeter\nsidebar_label: greeter:greeter\n---\n\n",
+		"Greets the `<user>` by \\{name\\}\n\n",
+		"Greets the user\n\n```go\nfunc Greet() {}\n```\n\n",
+	} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("expected %q in the package page:\n%s", expected, page)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package site
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRegexp     = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	listItemRegexp    = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+(.*)$`)
+	codeSpanRegexp    = regexp.MustCompile("`([^`]+)`")
+	linkRegexp        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongRegexp      = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	emphasisRegexp    = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	safeLinkURLRegexp = regexp.MustCompile(`^(https?://|mailto:|#|\.{0,2}/|[A-Za-z0-9_.-]+(/|$|#))`)
+)
+
+// markdownHTML renders the subset of markdown the LLM summaries use:
+// headings, paragraphs, flat lists, fenced code blocks and the inline code, emphasis and links.
+// Everything else is kept as the escaped text
+func markdownHTML(text string) template.HTML {
+	var b strings.Builder
+	paragraph := []string{}
+	listTag := ""
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			fmt.Fprintf(&b, "<p>%s</p>\n", inlineHTML(strings.Join(paragraph, " ")))
+			paragraph = paragraph[:0]
+		}
+	}
+	closeList := func() {
+		if listTag != "" {
+			fmt.Fprintf(&b, "</%s>\n", listTag)
+			listTag = ""
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flushParagraph()
+			closeList()
+			code := []string{}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", html.EscapeString(strings.Join(code, "\n")))
+		case trimmed == "":
+			flushParagraph()
+			closeList()
+		case headingRegexp.MatchString(trimmed):
+			flushParagraph()
+			closeList()
+			match := headingRegexp.FindStringSubmatch(trimmed)
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", len(match[1]), inlineHTML(match[2]), len(match[1]))
+		case listItemRegexp.MatchString(line):
+			flushParagraph()
+			match := listItemRegexp.FindStringSubmatch(line)
+			tag := "ul"
+			if strings.IndexAny(match[1], ".)") > 0 {
+				tag = "ol"
+			}
+			if tag != listTag {
+				closeList()
+				fmt.Fprintf(&b, "<%s>\n", tag)
+				listTag = tag
+			}
+			fmt.Fprintf(&b, "<li>%s</li>\n", inlineHTML(match[2]))
+		default:
+			closeList()
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	flushParagraph()
+	closeList()
+	return template.HTML(b.String())
+}
+
+// inlineHTML escapes the text and renders the code spans, links and emphasis,
+// the code span content is not formatted
+func inlineHTML(text string) string {
+	parts := codeSpanRegexp.Split(text, -1)
+	codes := codeSpanRegexp.FindAllStringSubmatch(text, -1)
+	var b strings.Builder
+	for i, part := range parts {
+		part = html.EscapeString(part)
+		part = linkRegexp.ReplaceAllStringFunc(part, func(link string) string {
+			match := linkRegexp.FindStringSubmatch(link)
+			if !safeLinkURLRegexp.MatchString(html.UnescapeString(match[2])) {
+				return link
+			}
+			return fmt.Sprintf(`<a href="%s">%s</a>`, match[2], match[1])
+		})
+		part = strongRegexp.ReplaceAllString(part, "<strong>$1$2</strong>")
+		part = emphasisRegexp.ReplaceAllString(part, "<em>$1</em>")
+		b.WriteString(part)
+		if i < len(codes) {
+			fmt.Fprintf(&b, "<code>%s</code>", html.EscapeString(codes[i][1]))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,255 @@
+package site
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	packagerunner "github.com/JackBekket/reflexia/pkg/package_runner"
+)
+
+//go:embed assets
+var assets embed.FS
+
+var pageTemplate = template.Must(template.New("site").Funcs(template.FuncMap{
+	"markdown": markdownHTML,
+	"page":     pagePath,
+}).ParseFS(assets, "assets/page.html"))
+
+// Site is the static documentation of the projects of one workdir
+type Site struct {
+	Title string
+	// SourceURL is the base URL of the workdir source files, e.g. the GitHub blob URL
+	// or the file:// URL of the local workdir, the source links are omitted if it is empty
+	SourceURL string
+	Projects  []Project
+}
+
+type Project struct {
+	// Key is the workdir relative project root joined with the project config name
+	Key      string
+	Summary  string
+	Packages []Package
+}
+
+type Package struct {
+	Key string
+	// Dir is the workdir relative slash separated package directory
+	Dir     string
+	Summary string
+	Files   []File
+
+	page string
+}
+
+type File struct {
+	// Path is the workdir relative slash separated file path
+	Path    string
+	Summary string
+
+	page string
+}
+
+// searchEntry is the search_index.js entry of the package or file page
+type searchEntry struct {
+	Title string `json:"title"`
+	Kind  string `json:"kind"`
+	Path  string `json:"path"`
+	Text  string `json:"text"`
+}
+
+// treeNode is the sidebar package tree directory or package
+type treeNode struct {
+	Name     string
+	Page     string
+	Children []*treeNode
+
+	dir bool
+}
+
+// pageData is the page template data, Root is the relative path of the site root
+type pageData struct {
+	Site       Site
+	Title      string
+	Root       string
+	Source     template.URL
+	SourcePath string
+	Tree       []*treeNode
+	Content    template.HTML
+}
+
+// Render writes the self-contained HTML site into the output:
+// index.html with the project summaries, packages/*.html and files/*.html pages,
+// the search_index.js used by the sidebar search, search.js and style.css
+func Render(output packagerunner.Output, site Site) error {
+	site = assignPages(site)
+	tree := packageTree(site)
+
+	for _, asset := range []string{"style.css", "search.js"} {
+		content, err := assets.ReadFile("assets/" + asset)
+		if err != nil {
+			return err
+		}
+		if err := output.WriteFile(asset, content); err != nil {
+			return fmt.Errorf("write %s: %w", asset, err)
+		}
+	}
+
+	writePage := func(name string, data pageData, contentTemplate string, content any) error {
+		var b bytes.Buffer
+		if err := pageTemplate.ExecuteTemplate(&b, contentTemplate, content); err != nil {
+			return fmt.Errorf("render %s: %w", name, err)
+		}
+		data.Site = site
+		data.Tree = tree
+		data.Content = template.HTML(b.String())
+		b.Reset()
+		if err := pageTemplate.ExecuteTemplate(&b, "page", data); err != nil {
+			return fmt.Errorf("render %s: %w", name, err)
+		}
+		if err := output.WriteFile(name, b.Bytes()); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := writePage("index.html", pageData{Title: "Overview", Root: "./"}, "index", site); err != nil {
+		return err
+	}
+	index := []searchEntry{}
+	for _, project := range site.Projects {
+		for _, pkg := range project.Packages {
+			if err := writePage(pkg.page, pageData{
+				Title:      pkg.Key,
+				Root:       "../",
+				Source:     sourceLink(site.SourceURL, pkg.Dir),
+				SourcePath: pkg.Dir,
+			}, "package", pkg); err != nil {
+				return err
+			}
+			index = append(index, searchEntry{Title: pkg.Key, Kind: "package " + project.Key, Path: pkg.page, Text: pkg.Summary})
+
+			for _, file := range pkg.Files {
+				if err := writePage(file.page, pageData{
+					Title:      file.Path,
+					Root:       "../",
+					Source:     sourceLink(site.SourceURL, file.Path),
+					SourcePath: file.Path,
+				}, "file", file); err != nil {
+					return err
+				}
+				index = append(index, searchEntry{Title: file.Path, Kind: "file of " + pkg.Key, Path: file.page, Text: file.Summary})
+			}
+		}
+	}
+
+	content, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("encode search index: %w", err)
+	}
+	// The index is a script rather than a json file, so the site works from the file:// URLs
+	return output.WriteFile("search_index.js", fmt.Appendf(nil, "window.searchIndex = %s;\n", content))
+}
+
+var slugRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// assignPages sets the unique page paths of the packages and files
+func assignPages(site Site) Site {
+	used := map[string]bool{}
+	unique := func(dir, name string) string {
+		slug := strings.Trim(slugRegexp.ReplaceAllString(name, "_"), "._")
+		page := path.Join(dir, slug+".html")
+		for i := 2; used[page]; i++ {
+			page = path.Join(dir, fmt.Sprintf("%s_%d.html", slug, i))
+		}
+		used[page] = true
+		return page
+	}
+
+	projects := make([]Project, len(site.Projects))
+	for i, project := range site.Projects {
+		projects[i] = project
+		projects[i].Packages = make([]Package, len(project.Packages))
+		for j, pkg := range project.Packages {
+			name := pkg.Key
+			if len(site.Projects) > 1 {
+				name = project.Key + "_" + pkg.Key
+			}
+			pkg.page = unique("packages", name)
+			files := make([]File, len(pkg.Files))
+			for k, file := range pkg.Files {
+				file.page = unique("files", file.Path)
+				files[k] = file
+			}
+			pkg.Files = files
+			projects[i].Packages[j] = pkg
+		}
+	}
+	site.Projects = projects
+	return site
+}
+
+// packageTree nests the packages by their directories, the directory links to its first package,
+// the other packages of the same directory are its children.
+// The projects are the top level nodes if there are several of them
+func packageTree(site Site) []*treeNode {
+	roots := []*treeNode{}
+	for _, project := range site.Projects {
+		projectNode := &treeNode{Name: project.Key}
+		for _, pkg := range project.Packages {
+			node := projectNode
+			if pkg.Dir != "." {
+				for _, part := range strings.Split(pkg.Dir, "/") {
+					i := slices.IndexFunc(node.Children, func(child *treeNode) bool {
+						return child.dir && child.Name == part
+					})
+					if i < 0 {
+						node.Children = append(node.Children, &treeNode{Name: part, dir: true})
+						i = len(node.Children) - 1
+					}
+					node = node.Children[i]
+				}
+			}
+			if node.dir && node.Page == "" {
+				node.Page = pkg.page
+			} else {
+				node.Children = append(node.Children, &treeNode{Name: pkg.Key, Page: pkg.page})
+			}
+		}
+		roots = append(roots, projectNode)
+	}
+	if len(roots) == 1 {
+		return roots[0].Children
+	}
+	return roots
+}
+
+// pagePath is the site root relative page of the package or file for the templates
+func pagePath(v any) string {
+	switch v := v.(type) {
+	case Package:
+		return v.page
+	case File:
+		return v.page
+	}
+	return ""
+}
+
+// sourceLink is trusted so the file:// URLs are not filtered by the template
+func sourceLink(sourceURL, relPath string) template.URL {
+	if sourceURL == "" {
+		return ""
+	}
+	parts := strings.Split(relPath, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return template.URL(strings.TrimSuffix(sourceURL, "/") + "/" + strings.Join(parts, "/"))
+}
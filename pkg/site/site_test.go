@@ -0,0 +1,75 @@
+package site
+
+import (
+	"strings"
+	"testing"
+
+	packagerunner "github.com/JackBekket/reflexia/pkg/package_runner"
+)
+
+func TestMarkdownHTML(t *testing.T) {
+	rendered := string(markdownHTML("# Greeter\n\nGreets the **user** via `fmt.Println(\"<b>\")`,\nsee [docs](../README.md).\n\n" +
+		"- first\n- [bad](javascript:alert(1))\n\n1. one\n\n```go\nif a < b {}\n```\n<script>"))
+	for _, expected := range []string{
+		"<h1>Greeter</h1>",
+		"<p>Greets the <strong>user</strong> via <code>fmt.Println(&#34;&lt;b&gt;&#34;)</code>, see <a href=\"../README.md\">docs</a>.</p>",
+		"<ul>\n<li>first</li>\n<li>[bad](javascript:alert(1))</li>\n</ul>",
+		"<ol>\n<li>one</li>\n</ol>",
+		"<pre><code>if a &lt; b {}</code></pre>",
+		"<p>&lt;script&gt;</p>",
+	} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("expected %q in:\n%s", expected, rendered)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	output := packagerunner.NewMemoryOutput()
+	if err := Render(output, Site{
+		Title:     "sample",
+		SourceURL: "https://github.com/owner/sample/blob/main/",
+		Projects: []Project{{
+			Key:     "go.toml",
+			Summary: "Sample project",
+			Packages: []Package{
+				{Key: ".:main", Dir: ".", Summary: "Main package", Files: []File{{Path: "main.go", Summary: "Entry point"}}},
+				{Key: "internal/greeter:greeter", Dir: "internal/greeter", Summary: "Greeter package", Files: []File{
+					{Path: "internal/greeter/greeter.go", Summary: "Greets the user"},
+				}},
+				{Key: "internal/greeter:greeter_test", Dir: "internal/greeter"},
+			},
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	names := strings.Join(output.Names(), " ")
+	if names != "files/internal_greeter_greeter.go.html files/main.go.html index.html "+
+		"packages/internal_greeter_greeter.html packages/internal_greeter_greeter_test.html packages/main.html "+
+		"search.js search_index.js style.css" {
+		t.Fatalf("unexpected site files %s", names)
+	}
+	page, err := output.ReadFile("files/internal_greeter_greeter.go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`<base href="../">`,
+		`<a href="https://github.com/owner/sample/blob/main/internal/greeter/greeter.go">internal/greeter/greeter.go</a>`,
+		`<summary><a href="packages/internal_greeter_greeter.html">greeter</a></summary>`,
+		`<a href="packages/internal_greeter_greeter_test.html">internal/greeter:greeter_test</a>`,
+		`<p>Greets the user</p>`,
+	} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("expected %q in the file page:\n%s", expected, page)
+		}
+	}
+	index, err := output.ReadFile("search_index.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), `{"title":"main.go","kind":"file of .:main","path":"files/main.go.html","text":"Entry point"}`) {
+		t.Errorf("unexpected search index %s", index)
+	}
+}
@@ -0,0 +1,144 @@
+package summarize
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rough average of characters per token for code in BPE tokenizers
+const charsPerToken = 4
+
+func EstimateTokens(content string) int {
+	return (utf8.RuneCountInString(content) + charsPerToken - 1) / charsPerToken
+}
+
+// SplitLines splits content into windows of whole lines
+// each estimated to fit into chunkTokens, the lines longer than chunkTokens,
+// such as the minified code ones, are split by runes
+func SplitLines(content string, chunkTokens int) []string {
+	chunkTokens = max(chunkTokens, 1)
+	chunks := []string{}
+	current := strings.Builder{}
+	currentTokens := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		for _, part := range splitRunes(line, chunkTokens*charsPerToken) {
+			partTokens := EstimateTokens(part)
+			if currentTokens > 0 && currentTokens+partTokens > chunkTokens {
+				chunks = append(chunks, current.String())
+				current.Reset()
+				currentTokens = 0
+			}
+			current.WriteString(part)
+			currentTokens += partTokens
+		}
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// splitRunes splits text into parts of at most size runes
+func splitRunes(text string, size int) []string {
+	parts := []string{}
+	for utf8.RuneCountInString(text) > size {
+		end := 0
+		for range size {
+			_, width := utf8.DecodeRuneInString(text[end:])
+			end += width
+		}
+		parts = append(parts, text[:end])
+		text = text[end:]
+	}
+	return append(parts, text)
+}
+
+// SplitGoDecls splits go source on top level declaration boundaries,
+// prefixing each chunk with the package clause and imports.
+// The header taking more than a half of chunkTokens is reduced to the package clause, or dropped if it is still too large.
+// Declarations that do not fit into chunkTokens alone are split by lines
+func SplitGoDecls(content string, chunkTokens int) ([]string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+
+	headerEnd := offset(file.Name.End())
+	decls := []ast.Decl{}
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			headerEnd = offset(gen.End())
+			continue
+		}
+		decls = append(decls, decl)
+	}
+	header := content[:headerEnd] + "\n\n"
+	if EstimateTokens(header) > chunkTokens/2 {
+		header = content[:offset(file.Name.End())] + "\n\n"
+	}
+	if EstimateTokens(header) > chunkTokens/2 {
+		header = ""
+	}
+	bodyTokens := max(chunkTokens-EstimateTokens(header), 1)
+
+	chunks := []string{}
+	current := strings.Builder{}
+	currentTokens := 0
+	flush := func() {
+		if currentTokens > 0 {
+			chunks = append(chunks, header+current.String())
+			current.Reset()
+			currentTokens = 0
+		}
+	}
+	starts := make([]int, len(decls))
+	for i, decl := range decls {
+		start := decl.Pos()
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+		}
+		starts[i] = offset(start)
+	}
+	for i := range decls {
+		// Free floating comments after the last declaration are kept with it
+		end := len(content)
+		if i < len(decls)-1 {
+			end = starts[i+1]
+		}
+		text := content[starts[i]:end]
+		tokens := EstimateTokens(text)
+
+		if tokens > bodyTokens {
+			flush()
+			for _, part := range SplitLines(text, bodyTokens) {
+				chunks = append(chunks, header+part)
+			}
+			continue
+		}
+		if currentTokens+tokens > bodyTokens {
+			flush()
+		}
+		current.WriteString(text)
+		currentTokens += tokens
+	}
+	flush()
+
+	if len(chunks) == 0 {
+		chunks = append(chunks, content)
+	}
+	return chunks, nil
+}
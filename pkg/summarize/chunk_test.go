@@ -0,0 +1,85 @@
+package summarize
+
+import (
+	"strings"
+	"testing"
+)
+
+const chunkTestSource = `package sample
+
+import "fmt"
+
+// First prints the first line
+func First() {
+	fmt.Println("first")
+}
+
+// Second prints the second line
+func Second() {
+	fmt.Println("second")
+}
+
+type Third struct {
+	Value string
+}
+`
+
+func TestSplitGoDecls(t *testing.T) {
+	chunks, err := SplitGoDecls(chunkTestSource, EstimateTokens(chunkTestSource)/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("expected source to be split, got %d chunks", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if !strings.HasPrefix(chunk, "package sample\n\nimport \"fmt\"") {
+			t.Fatalf("chunk does not start with the file header:\n%s", chunk)
+		}
+	}
+	joined := strings.Join(chunks, "")
+	for _, decl := range []string{
+		"// First prints the first line\nfunc First()",
+		"// Second prints the second line\nfunc Second()",
+		"type Third struct",
+	} {
+		if strings.Count(joined, decl) != 1 {
+			t.Fatalf("declaration %q should be present in exactly one chunk", decl)
+		}
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	content := strings.Repeat("0123456789abcdef\n", 10)
+	chunks := SplitLines(content, 10)
+	if len(chunks) != 5 {
+		t.Fatalf("expected 5 chunks, got %d", len(chunks))
+	}
+	if strings.Join(chunks, "") != content {
+		t.Fatal("joined chunks differ from the content")
+	}
+}
+
+func TestSplitLongLines(t *testing.T) {
+	content := "short\n" + strings.Repeat("минифицированный;", 20) + "\nend\n"
+	chunks := SplitLines(content, 10)
+	if strings.Join(chunks, "") != content {
+		t.Fatal("joined chunks differ from the content")
+	}
+	for _, chunk := range chunks {
+		if tokens := EstimateTokens(chunk); tokens > 10 {
+			t.Fatalf("chunk of %d tokens exceeds the limit:\n%s", tokens, chunk)
+		}
+	}
+
+	// The imports larger than the budget are dropped rather than splitting every line into a chunk
+	source := "package sample\n\nimport (\n" + strings.Repeat("\t\"example.com/some/long/import/path\"\n", 20) + ")\n" +
+		strings.Repeat("func F() {}\n", 20)
+	goChunks, err := SplitGoDecls(source, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goChunks) > 3 || !strings.HasPrefix(goChunks[0], "package sample\n\nfunc F() {}") {
+		t.Fatalf("unexpected %d chunks:\n%s", len(goChunks), strings.Join(goChunks, "\n---\n"))
+	}
+}
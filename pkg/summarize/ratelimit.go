@@ -0,0 +1,56 @@
+package summarize
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter limits requests and estimated tokens per minute,
+// it is meant to be shared between all of the LLM requests of a run
+type RateLimiter struct {
+	requests *rate.Limiter
+	tokens   *rate.Limiter
+}
+
+// NewRateLimiter returns nil if both of the limits are disabled with zero values
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	if requestsPerMinute <= 0 && tokensPerMinute <= 0 {
+		return nil
+	}
+	l := &RateLimiter{}
+	if requestsPerMinute > 0 {
+		l.requests = rate.NewLimiter(rate.Every(time.Minute/time.Duration(requestsPerMinute)), 1)
+	}
+	if tokensPerMinute > 0 {
+		l.tokens = rate.NewLimiter(rate.Limit(float64(tokensPerMinute)/60), tokensPerMinute)
+	}
+	return l
+}
+
+// Wait blocks until the request with the given prompt tokens estimation is allowed
+func (l *RateLimiter) Wait(ctx context.Context, promptTokens int) error {
+	if l == nil {
+		return nil
+	}
+	if l.requests != nil {
+		if err := l.requests.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if l.tokens != nil {
+		if err := l.tokens.WaitN(ctx, min(promptTokens, l.tokens.Burst())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Consume charges the response tokens, delaying the following requests
+func (l *RateLimiter) Consume(responseTokens int) {
+	if l == nil || l.tokens == nil {
+		return
+	}
+	l.tokens.ReserveN(time.Now(), min(responseTokens, l.tokens.Burst()))
+}
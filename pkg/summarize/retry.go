@@ -0,0 +1,101 @@
+package summarize
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryConfig controls retries of the failed LLM requests,
+// zero MaxRetries disables retries
+type RetryConfig struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var retryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusConflict,
+	http.StatusTooEarly,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// LLM clients return the HTTP errors as plain strings, such as
+// "API returned unexpected status code: 429: ..." or "503 Service Unavailable: ..."
+var statusCodeRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)status code:?\s*(\d{3})\b`),
+	regexp.MustCompile(`(?:^|:\s)(\d{3}) [A-Z][a-z]+`),
+}
+
+// isRetryable reports whether the failed request is worth another attempt,
+// the request errors of the done caller context, including its deadline, are not
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	message := err.Error()
+	for _, re := range statusCodeRegexps {
+		for _, match := range re.FindAllStringSubmatch(message, -1) {
+			code, _ := strconv.Atoi(match[1])
+			for _, retryable := range retryableStatusCodes {
+				if code == retryable {
+					return true
+				}
+			}
+		}
+	}
+	return strings.Contains(message, "connection reset by peer")
+}
+
+// backoff returns the exponential delay for the attempt with a random jitter
+// in the upper half of the delay
+func (c RetryConfig) backoff(attempt int) time.Duration {
+	delay := c.InitialBackoff
+	if delay <= 0 {
+		delay = time.Second
+	}
+	for range attempt {
+		delay *= 2
+		if c.MaxBackoff > 0 && delay >= c.MaxBackoff {
+			delay = c.MaxBackoff
+			break
+		}
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,44 @@
+package summarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	for err, retryable := range map[error]bool{
+		errors.New("API returned unexpected status code: 429: rate limit exceeded"):   true,
+		errors.New("API returned unexpected status code: 502"):                        true,
+		fmt.Errorf("generate: %w", errors.New("503 Service Unavailable: overloaded")): true,
+		errors.New("API returned unexpected status code: 400: bad request"):           false,
+		errors.New("API returned unexpected status code: 401: invalid token"):         false,
+		fmt.Errorf("request: %w", context.DeadlineExceeded):                           true,
+		fmt.Errorf("request: %w", context.Canceled):                                   false,
+	} {
+		if isRetryable(context.Background(), err) != retryable {
+			t.Errorf("isRetryable(%q) should be %v", err, retryable)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+	if isRetryable(ctx, fmt.Errorf("request: %w", ctx.Err())) {
+		t.Error("the request of the expired caller context should not be retried")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := RetryConfig{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, upper := range []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second,
+	} {
+		delay := c.backoff(attempt)
+		if delay < upper/2 || delay > upper {
+			t.Errorf("attempt %d backoff %s is out of [%s, %s]", attempt, delay, upper/2, upper)
+		}
+	}
+}
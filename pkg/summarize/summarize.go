@@ -6,13 +6,13 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"io/fs"
-	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/JackBekket/reflexia/pkg/cache"
 	"github.com/Swarmind/libagent/pkg/agent"
 	agentUtil "github.com/Swarmind/libagent/pkg/util"
+	"github.com/rs/zerolog/log"
 	"github.com/tmc/langchaingo/llms"
 )
 
@@ -21,35 +21,110 @@ type SummarizeService struct {
 	LlmOptions     []llms.CallOption
 	OverwriteCache bool
 	IgnoreCache    bool
-	CachePath      string
+	Cache          cache.Cache
 	StopWords      []string
 	Model          string
+	// Project is recorded into the cache entries metadata
+	Project string
+	// Prices are used to fill the RequestInfo.Cost
+	Prices PriceTable
+
+	Retry       RetryConfig
+	RateLimiter *RateLimiter
+}
+
+// RequestInfo describes how the LLMRequestWithInfo response was obtained
+type RequestInfo struct {
+	CacheHit bool
+	Retries  int
+	// Tokens are reported by the UsageModel wrapped LLM,
+	// otherwise they are estimated from the prompt and response length
+	PromptTokens     int
+	CompletionTokens int
+	EstimatedTokens  bool
+	// Duration is the wall time of the model call, including retries
+	Duration time.Duration
+	Cost     float64
+}
+
+type metadataKey struct{}
+
+// WithMetadata attaches the request origin description
+// which is saved along with the cached response
+func WithMetadata(ctx context.Context, metadata cache.Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, metadata)
 }
 
 func (s SummarizeService) LLMRequest(ctx context.Context, format string, a ...any) (string, error) {
+	response, _, err := s.LLMRequestWithInfo(ctx, format, a...)
+	return response, err
+}
+
+func (s SummarizeService) LLMRequestWithInfo(
+	ctx context.Context, format string, a ...any,
+) (string, RequestInfo, error) {
+	info := RequestInfo{}
 	finalPrompt := fmt.Sprintf(format, a...)
 	cacheHash, err := hashStrings(finalPrompt + s.Model)
 	if err != nil {
-		return "", err
+		return "", info, err
 	}
 	response := ""
 
-	if !(s.OverwriteCache || s.IgnoreCache) {
-		response, err = loadCache(s.CachePath, cacheHash)
-		if err != nil && !errors.Is(err, fs.ErrNotExist) {
-			return "", err
+	useCache := s.Cache != nil && !s.IgnoreCache
+
+	if useCache && !s.OverwriteCache {
+		entry, err := s.Cache.Load(cacheHash)
+		if err != nil && !errors.Is(err, cache.ErrNotFound) {
+			return "", info, err
 		}
+		response = entry.Content
 	}
 
 	if response != "" {
-		return response, nil
+		info.CacheHit = true
+		return response, info, nil
 	}
 
-	if response, err = s.Agent.SimpleRun(
-		ctx, finalPrompt, s.LlmOptions...,
-	); err != nil {
-		return "", err
+	promptTokens := EstimateTokens(finalPrompt)
+	started := time.Now()
+	recorder := &usageRecorder{}
+	for attempt := 0; ; attempt++ {
+		if err = s.RateLimiter.Wait(ctx, promptTokens); err != nil {
+			return "", info, err
+		}
+		recorder = &usageRecorder{}
+		response, err = s.Agent.SimpleRun(
+			context.WithValue(ctx, usageKey{}, recorder), finalPrompt, s.LlmOptions...,
+		)
+		if err == nil {
+			break
+		}
+		if attempt >= s.Retry.MaxRetries || !isRetryable(ctx, err) {
+			return "", info, err
+		}
+
+		info.Retries++
+		delay := s.Retry.backoff(attempt)
+		log.Warn().Err(err).Msgf(
+			"LLM request attempt %d/%d failed, retrying in %s",
+			attempt+1, s.Retry.MaxRetries+1, delay,
+		)
+		if err := sleepContext(ctx, delay); err != nil {
+			return "", info, err
+		}
 	}
+	info.Duration = time.Since(started)
+	if recorder.reported {
+		info.PromptTokens = recorder.promptTokens
+		info.CompletionTokens = recorder.completionTokens
+	} else {
+		info.EstimatedTokens = true
+		info.PromptTokens = promptTokens
+		info.CompletionTokens = EstimateTokens(response)
+	}
+	info.Cost = s.Prices.Cost(s.Model, info.PromptTokens, info.CompletionTokens)
+	s.RateLimiter.Consume(info.CompletionTokens)
 
 	response = agentUtil.RemoveThinkTag(response)
 	for _, stopWord := range s.StopWords {
@@ -57,13 +132,26 @@ func (s SummarizeService) LLMRequest(ctx context.Context, format string, a ...an
 	}
 	response = strings.TrimSpace(response)
 
-	if !s.IgnoreCache {
-		if err = saveCache(s.CachePath, cacheHash, response); err != nil {
-			return "", err
+	if useCache {
+		metadata, _ := ctx.Value(metadataKey{}).(cache.Metadata)
+		metadata.Model = s.Model
+		metadata.Project = s.Project
+		metadata.CreatedAt = time.Now()
+		metadata.PromptBytes = len(finalPrompt)
+		metadata.PromptTokens = info.PromptTokens
+		metadata.ResponseBytes = len(response)
+		metadata.ResponseTokens = info.CompletionTokens
+
+		if err = s.Cache.Save(cache.Entry{
+			Key:      cacheHash,
+			Content:  response,
+			Metadata: metadata,
+		}); err != nil {
+			return "", info, err
 		}
 	}
 
-	return response, nil
+	return response, info, nil
 }
 
 func hashStrings(a ...string) (string, error) {
@@ -77,23 +165,3 @@ func hashStrings(a ...string) (string, error) {
 	hashBytes := hash.Sum(nil)
 	return hex.EncodeToString(hashBytes), nil
 }
-
-func loadCache(cachePath, hash string) (string, error) {
-	cache, err := os.ReadFile(filepath.Join(cachePath, hash))
-	return string(cache), err
-}
-
-func saveCache(cachePath, hash, content string) error {
-	if err := os.MkdirAll(cachePath, os.ModePerm); err != nil {
-		return err
-	}
-	file, err := os.Create(filepath.Join(cachePath, hash))
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	if _, err = file.WriteString(content); err != nil {
-		return err
-	}
-	return nil
-}
@@ -0,0 +1,127 @@
+package summarize
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// UsageModel records the token usage reported by the wrapped model
+// into the LLMRequestWithInfo request info
+type UsageModel struct {
+	llms.Model
+}
+
+func (m UsageModel) GenerateContent(
+	ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	resp, err := m.Model.GenerateContent(ctx, messages, options...)
+	if err != nil {
+		return resp, err
+	}
+	recorder, ok := ctx.Value(usageKey{}).(*usageRecorder)
+	if !ok || len(resp.Choices) == 0 {
+		return resp, nil
+	}
+
+	info := resp.Choices[0].GenerationInfo
+	promptTokens, promptOk := generationInfoInt(info, "PromptTokens", "InputTokens", "prompt_eval_count")
+	completionTokens, completionOk := generationInfoInt(info, "CompletionTokens", "OutputTokens", "eval_count")
+	if promptOk || completionOk {
+		recorder.add(promptTokens, completionTokens)
+	}
+	return resp, nil
+}
+
+type usageKey struct{}
+
+type usageRecorder struct {
+	mu               sync.Mutex
+	reported         bool
+	promptTokens     int
+	completionTokens int
+}
+
+func (r *usageRecorder) add(promptTokens, completionTokens int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reported = true
+	r.promptTokens += promptTokens
+	r.completionTokens += completionTokens
+}
+
+func generationInfoInt(info map[string]any, keys ...string) (int, bool) {
+	for _, key := range keys {
+		switch v := info[key].(type) {
+		case int:
+			return v, true
+		case int32:
+			return int(v), true
+		case int64:
+			return int(v), true
+		case float64:
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// ModelPrice is the price in arbitrary currency per million of tokens
+type ModelPrice struct {
+	Prompt     float64 `toml:"prompt"`
+	Completion float64 `toml:"completion"`
+}
+
+// PriceTable maps model name regular expressions to their prices
+type PriceTable map[string]ModelPrice
+
+// LoadPriceTable reads the toml file of the following structure:
+//
+//	["gpt-4o.*"]
+//	prompt = 2.5
+//	completion = 10
+func LoadPriceTable(path string) (PriceTable, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	table := PriceTable{}
+	if err := toml.Unmarshal(content, &table); err != nil {
+		return nil, err
+	}
+	for expr := range table {
+		if _, err := regexp.Compile(expr); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// Cost uses the price of the exact model name, or of the longest matching expression
+// as the most specific one, the same long expressions are tried in the sorted order
+func (t PriceTable) Cost(model string, promptTokens, completionTokens int) float64 {
+	if price, ok := t[model]; ok {
+		return price.cost(promptTokens, completionTokens)
+	}
+	exprs := slices.SortedFunc(maps.Keys(t), func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), strings.Compare(a, b))
+	})
+	for _, expr := range exprs {
+		if matched, _ := regexp.MatchString("^(?:"+expr+")$", model); matched {
+			return t[expr].cost(promptTokens, completionTokens)
+		}
+	}
+	return 0
+}
+
+func (p ModelPrice) cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Prompt + float64(completionTokens)*p.Completion) / 1_000_000
+}
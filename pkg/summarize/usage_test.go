@@ -0,0 +1,26 @@
+package summarize
+
+import "testing"
+
+func TestPriceTableCost(t *testing.T) {
+	table := PriceTable{
+		"gpt-4.*":     {Prompt: 30, Completion: 60},
+		"gpt-4o.*":    {Prompt: 2.5, Completion: 10},
+		"gpt-4o-mini": {Prompt: 0.15, Completion: 0.6},
+		"gpt-4o-m.*":  {Prompt: 1, Completion: 1},
+	}
+	for model, expected := range map[string]float64{
+		"gpt-4-turbo":        90,
+		"gpt-4o-2024-08-06":  12.5,
+		"gpt-4o-mini":        0.75,
+		"gpt-4o-mini-latest": 2,
+		"claude":             0,
+	} {
+		// The overlapping expressions are resolved the same on every map iteration order
+		for range 10 {
+			if cost := table.Cost(model, 1_000_000, 1_000_000); cost != expected {
+				t.Fatalf("%s: expected cost %v, got %v", model, expected, cost)
+			}
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Package projectconfig embeds the default project configs into the binary
+package projectconfig
+
+import "embed"
+
+//go:embed *.toml
+var FS embed.FS
+
+// Schema is the JSON Schema of the project config files for the editors
+//
+//go:embed schema.json
+var Schema []byte